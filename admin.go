@@ -0,0 +1,95 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// logLevelCollector exposes the logger's current level as an info metric.
+type logLevelCollector struct {
+	logger *logrus.Logger
+	desc   *prometheus.Desc
+}
+
+func newLogLevelCollector(namespace string, logger *logrus.Logger) *logLevelCollector {
+	return &logLevelCollector{
+		logger: logger,
+		desc: prometheus.NewDesc(
+			namespace+"_exporter_log_level",
+			"Current logging level of ceph_exporter",
+			[]string{"level"},
+			nil,
+		),
+	}
+}
+
+// Describe sends the descriptor of the log level metric to the channel.
+func (c *logLevelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect sends the current log level to the channel.
+func (c *logLevelCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, c.logger.GetLevel().String())
+}
+
+// logLevelHandler reports the current log level on GET and changes it on POST,
+// e.g. `POST /loglevel?level=debug`.
+func logLevelHandler(logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintln(w, logger.GetLevel())
+		case http.MethodPost:
+			level, err := logrus.ParseLevel(r.URL.Query().Get("level"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			logger.SetLevel(level)
+			logger.WithField("level", level).Info("log level changed")
+			fmt.Fprintln(w, level)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// reloadHandler reloads the exporter config at path on POST, keeping the
+// clusters exported if it is invalid.
+func reloadHandler(clusters *clusterSet, path string, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := clusters.reload(path); err != nil {
+			logger.WithError(err).Error("error reloading config, keeping the clusters exported")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,110 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package asok
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/digitalocean/ceph_exporter/ceph"
+)
+
+// DefaultSocketDir is where Ceph daemons create their admin sockets unless
+// configured otherwise.
+const DefaultSocketDir = "/var/run/ceph"
+
+// AdminSocketConn implements the DaemonConn interface by talking to the admin
+// sockets of the daemons running on the local host.
+type AdminSocketConn struct {
+	socketDir string
+	cluster   string
+	timeout   time.Duration
+	logger    *logrus.Logger
+}
+
+// *AdminSocketConn must implement the DaemonConn.
+var _ ceph.DaemonConn = &AdminSocketConn{}
+
+// NewAdminSocketConn returns a new AdminSocketConn for the daemons of the
+// named cluster, e.g. "ceph", whose admin sockets are found in socketDir as
+// $cluster-$name.asok. A new connection is made for every command, bounded by
+// timeout unless it is 0.
+func NewAdminSocketConn(socketDir, cluster string, timeout time.Duration, logger *logrus.Logger) *AdminSocketConn {
+	return &AdminSocketConn{
+		socketDir: socketDir,
+		cluster:   cluster,
+		timeout:   timeout,
+		logger:    logger,
+	}
+}
+
+// socketPath returns the path of the admin socket of the given daemon.
+func (c *AdminSocketConn) socketPath(daemon string) string {
+	return filepath.Join(c.socketDir, fmt.Sprintf("%s-%s.asok", c.cluster, daemon))
+}
+
+// DaemonCommand sends a JSON command such as {"prefix": "perf dump"} to the
+// admin socket of the daemon, and returns its response. The command is
+// terminated by a NUL byte, and the response is preceded by its length as a
+// 32-bit big-endian integer.
+func (c *AdminSocketConn) DaemonCommand(daemon string, args []byte) ([]byte, error) {
+	path := c.socketPath(daemon)
+	ll := c.logger.WithFields(logrus.Fields{
+		"args":   string(args),
+		"socket": path,
+	})
+
+	ll.Trace("connecting to admin socket to execute daemon command")
+
+	conn, err := net.DialTimeout("unix", path, c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to admin socket: %s", err)
+	}
+	defer conn.Close()
+
+	if c.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	ll.Trace("start executing daemon command")
+
+	cmd := make([]byte, len(args)+1)
+	copy(cmd, args)
+	if _, err := conn.Write(cmd); err != nil {
+		return nil, fmt.Errorf("error sending daemon command: %s", err)
+	}
+
+	var length uint32
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("error reading daemon command response length: %s", err)
+	}
+
+	buffer := make([]byte, length)
+	if _, err := io.ReadFull(conn, buffer); err != nil {
+		return nil, fmt.Errorf("error reading daemon command response: %s", err)
+	}
+
+	ll.Trace("complete executing daemon command")
+
+	return buffer, nil
+}
@@ -0,0 +1,96 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package asok
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// serveAdminSocket answers a single command on a fake admin socket of the
+// daemon in dir with the given response, and returns the command received.
+func serveAdminSocket(t *testing.T, dir, daemon string, response []byte) <-chan string {
+	l, err := net.Listen("unix", filepath.Join(dir, "ceph-"+daemon+".asok"))
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		cmd, err := bufio.NewReader(conn).ReadBytes(0)
+		if err != nil {
+			return
+		}
+		received <- string(cmd[:len(cmd)-1])
+
+		binary.Write(conn, binary.BigEndian, uint32(len(response)))
+		conn.Write(response)
+	}()
+
+	return received
+}
+
+func TestAdminSocketConnDaemonCommand(t *testing.T) {
+	dir := t.TempDir()
+	received := serveAdminSocket(t, dir, "osd.0", []byte(`{"osd": {"op_r": 12}}`))
+
+	conn := NewAdminSocketConn(dir, "ceph", time.Second, logrus.New())
+	buf, err := conn.DaemonCommand("osd.0", []byte(`{"prefix": "perf dump"}`))
+	require.NoError(t, err)
+
+	require.Equal(t, `{"prefix": "perf dump"}`, <-received)
+	require.Equal(t, `{"osd": {"op_r": 12}}`, string(buf))
+}
+
+func TestAdminSocketConnMissingSocket(t *testing.T) {
+	conn := NewAdminSocketConn(t.TempDir(), "ceph", time.Second, logrus.New())
+	_, err := conn.DaemonCommand("osd.0", []byte(`{"prefix": "perf dump"}`))
+	require.Error(t, err)
+}
+
+func TestAdminSocketConnTimeout(t *testing.T) {
+	dir := t.TempDir()
+
+	// accepts the connection but never answers
+	l, err := net.Listen("unix", filepath.Join(dir, "ceph-mon.a.asok"))
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(time.Second)
+	}()
+
+	conn := NewAdminSocketConn(dir, "ceph", 50*time.Millisecond, logrus.New())
+	start := time.Now()
+	_, err = conn.DaemonCommand("mon.a", []byte(`{"prefix": "perf dump"}`))
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
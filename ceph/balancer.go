@@ -0,0 +1,186 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// balancerModes are the modes reported by ceph_balancer_mode even when not
+// in use, so that a change of mode shows up as a series going to 0.
+var balancerModes = []string{"none", "crush-compat", "upmap"}
+
+// BalancerCollector reports the state of the balancer mgr module, to confirm
+// that it is actually moving data around.
+type BalancerCollector struct {
+	conn    Conn
+	logger  *logrus.Logger
+	version *Version
+
+	// Active shows whether the balancer is active. It is 0 when the
+	// balancer module is not loaded.
+	Active *prometheus.Desc
+
+	// Mode shows the mode the balancer runs in, 1 for the current mode and
+	// 0 for the others.
+	Mode *prometheus.Desc
+
+	// PendingOptimizations counts the optimization plans waiting to be
+	// executed.
+	PendingOptimizations *prometheus.Desc
+}
+
+// NewBalancerCollector creates a new BalancerCollector instance.
+func NewBalancerCollector(exporter *Exporter) *BalancerCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return &BalancerCollector{
+		conn:    exporter.Conn,
+		logger:  exporter.Logger,
+		version: exporter.Version,
+
+		Active: prometheus.NewDesc(
+			fmt.Sprintf("%s_balancer_active", namespace),
+			"Whether the balancer is active",
+			nil,
+			labels,
+		),
+		Mode: prometheus.NewDesc(
+			fmt.Sprintf("%s_balancer_mode", namespace),
+			"Mode of the balancer, 1 for the current mode",
+			[]string{"mode"},
+			labels,
+		),
+		PendingOptimizations: prometheus.NewDesc(
+			fmt.Sprintf("%s_balancer_pending_optimizations", namespace),
+			"Number of optimization plans waiting to be executed by the balancer",
+			nil,
+			labels,
+		),
+	}
+}
+
+type cephBalancerStatus struct {
+	Active bool              `json:"active"`
+	Mode   string            `json:"mode"`
+	Plans  []json.RawMessage `json:"plans"`
+}
+
+// balancerLoaded reports whether the balancer mgr module is loaded, which it
+// always is from Nautilus on.
+func (b *BalancerCollector) balancerLoaded() (bool, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "mgr module ls",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	buf, _, err := b.conn.MonCommand(cmd)
+	if err != nil {
+		return false, err
+	}
+
+	var ls cephMgrModuleLs
+	if err := json.Unmarshal(buf, &ls); err != nil {
+		return false, err
+	}
+
+	for _, module := range append(ls.AlwaysOnModules, ls.EnabledModules...) {
+		if module == "balancer" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (b *BalancerCollector) getBalancerStatus() (*cephBalancerStatus, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "balancer status",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := b.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &cephBalancerStatus{}
+	if err := json.Unmarshal(buf, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (b *BalancerCollector) collect(ch chan<- prometheus.Metric) error {
+	loaded, err := b.balancerLoaded()
+	if err != nil {
+		return err
+	}
+	if !loaded {
+		ch <- prometheus.MustNewConstMetric(b.Active, prometheus.GaugeValue, 0)
+		return nil
+	}
+
+	status, err := b.getBalancerStatus()
+	if err != nil {
+		return err
+	}
+
+	active := 0.0
+	if status.Active {
+		active = 1
+	}
+	ch <- prometheus.MustNewConstMetric(b.Active, prometheus.GaugeValue, active)
+
+	modes := make(map[string]float64, len(balancerModes))
+	for _, mode := range balancerModes {
+		modes[mode] = 0
+	}
+	modes[status.Mode] = 1
+	for mode, current := range modes {
+		ch <- prometheus.MustNewConstMetric(b.Mode, prometheus.GaugeValue, current, mode)
+	}
+
+	ch <- prometheus.MustNewConstMetric(b.PendingOptimizations, prometheus.GaugeValue, float64(len(status.Plans)))
+
+	return nil
+}
+
+// Describe sends the descriptors of the BalancerCollector metrics to the
+// provided channel.
+func (b *BalancerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.Active
+	ch <- b.Mode
+	ch <- b.PendingOptimizations
+}
+
+// Collect sends the state of the balancer to the provided channel.
+func (b *BalancerCollector) Collect(ch chan<- prometheus.Metric) {
+	b.logger.Debug("collecting balancer metrics")
+	if err := b.collect(ch); err != nil {
+		b.logger.WithError(err).Error("error collecting balancer metrics")
+	}
+}
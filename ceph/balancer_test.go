@@ -0,0 +1,116 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalancerCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		modules            string
+		status             string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:    "active upmap",
+			modules: `{"always_on_modules": ["balancer", "crash"], "enabled_modules": [], "disabled_modules": []}`,
+			status: `
+{
+	"active": true,
+	"last_optimize_duration": "0:00:00.012345",
+	"last_optimize_started": "Thu Mar 10 12:00:00 2022",
+	"mode": "upmap",
+	"optimize_result": "Optimization plan created successfully",
+	"plans": ["auto_2022-03-10_12:00:00", "auto_2022-03-10_12:01:00"]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_balancer_active{cluster="ceph"} 1`),
+				regexp.MustCompile(`ceph_balancer_mode{cluster="ceph",mode="upmap"} 1`),
+				regexp.MustCompile(`ceph_balancer_mode{cluster="ceph",mode="crush-compat"} 0`),
+				regexp.MustCompile(`ceph_balancer_mode{cluster="ceph",mode="none"} 0`),
+				regexp.MustCompile(`ceph_balancer_pending_optimizations{cluster="ceph"} 2`),
+			},
+		},
+		{
+			name:    "inactive",
+			modules: `{"enabled_modules": ["balancer"], "disabled_modules": []}`,
+			status: `
+{
+	"active": false,
+	"mode": "none",
+	"plans": []
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_balancer_active{cluster="ceph"} 0`),
+				regexp.MustCompile(`ceph_balancer_mode{cluster="ceph",mode="none"} 1`),
+				regexp.MustCompile(`ceph_balancer_mode{cluster="ceph",mode="upmap"} 0`),
+				regexp.MustCompile(`ceph_balancer_pending_optimizations{cluster="ceph"} 0`),
+			},
+		},
+		{
+			name:    "module not loaded",
+			modules: `{"enabled_modules": ["prometheus"], "disabled_modules": [{"name": "balancer", "can_run": true, "error_string": ""}]}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_balancer_active{cluster="ceph"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_balancer_mode`),
+				regexp.MustCompile(`ceph_balancer_pending_optimizations`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "mgr module ls"
+			})).Return([]byte(tt.modules), "", nil)
+			conn.On("MgrCommand", mock.Anything).Return([]byte(tt.status), "", nil)
+
+			collector := NewBalancerCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+
+			reg := prometheus.NewRegistry()
+			require.NoError(t, reg.Register(collector))
+
+			server := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), re.String())
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf), re.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mdsStateActive        = "up:active"
+	mdsStateStandbyReplay = "up:standby-replay"
+)
+
+// CephFSCollector collects information about the CephFS filesystems in the
+// cluster and the MDS daemons serving them. Clusters without any filesystem
+// configured only report their MDS daemon counts.
+type CephFSCollector struct {
+	conn    Conn
+	logger  *logrus.Logger
+	version *Version
+
+	// MDSActive shows the no. of MDS daemons active for a filesystem.
+	MDSActive *prometheus.Desc
+
+	// MDSStandbyReplay shows the no. of MDS daemons following an active rank
+	// of a filesystem in standby-replay.
+	MDSStandbyReplay *prometheus.Desc
+
+	// MDSUp shows the no. of MDS daemons up in the cluster, whether they
+	// hold a rank or not.
+	MDSUp *prometheus.Desc
+
+	// MDSStandby shows the no. of standby MDS daemons available to any
+	// filesystem in the cluster.
+	MDSStandby *prometheus.Desc
+
+	// MaxMDS shows the configured max_mds of a filesystem.
+	MaxMDS *prometheus.Desc
+
+	// MDSState reports the state of each MDS daemon holding a rank.
+	MDSState *prometheus.Desc
+
+	// Clients shows the no. of client sessions of a filesystem.
+	Clients *prometheus.Desc
+
+	// MDSRequestRate shows the rate of client requests served by a rank.
+	MDSRequestRate *prometheus.Desc
+
+	// MDSDentries shows the no. of dentries cached by a rank.
+	MDSDentries *prometheus.Desc
+
+	// MDSInodes shows the no. of inodes cached by a rank.
+	MDSInodes *prometheus.Desc
+
+	// MDSCaps shows the no. of capabilities handed out to clients by a rank.
+	MDSCaps *prometheus.Desc
+
+	// PoolUsedBytes shows the bytes used in each of a filesystem's pools.
+	PoolUsedBytes *prometheus.Desc
+
+	// PoolAvailBytes shows the bytes available to each of a filesystem's pools.
+	PoolAvailBytes *prometheus.Desc
+}
+
+// NewCephFSCollector creates a new CephFSCollector instance
+func NewCephFSCollector(exporter *Exporter) *CephFSCollector {
+	var (
+		subSystem = "fs"
+		fsLabels  = []string{"fs"}
+		mdsLabels = []string{"fs", "name", "rank"}
+	)
+
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return &CephFSCollector{
+		conn:    exporter.Conn,
+		logger:  exporter.Logger,
+		version: exporter.Version,
+
+		MDSActive: prometheus.NewDesc(fmt.Sprintf("%s_%s_mds_active", namespace, subSystem), "No. of active MDS daemons of the filesystem",
+			fsLabels, labels,
+		),
+		MDSStandbyReplay: prometheus.NewDesc(fmt.Sprintf("%s_%s_mds_standby_replay", namespace, subSystem), "No. of standby-replay MDS daemons of the filesystem",
+			fsLabels, labels,
+		),
+		MDSUp: prometheus.NewDesc(fmt.Sprintf("%s_mds_up", namespace), "No. of MDS daemons up in the cluster",
+			nil, labels,
+		),
+		MDSStandby: prometheus.NewDesc(fmt.Sprintf("%s_mds_standby", namespace), "No. of standby MDS daemons in the cluster",
+			nil, labels,
+		),
+		MaxMDS: prometheus.NewDesc(fmt.Sprintf("%s_%s_max_mds", namespace, subSystem), "Configured max_mds of the filesystem",
+			fsLabels, labels,
+		),
+		MDSState: prometheus.NewDesc(fmt.Sprintf("%s_%s_mds_state", namespace, subSystem), "State of the MDS daemons holding a rank of the filesystem",
+			append(mdsLabels, "state"), labels,
+		),
+		Clients: prometheus.NewDesc(fmt.Sprintf("%s_%s_clients", namespace, subSystem), "No. of client sessions of the filesystem",
+			fsLabels, labels,
+		),
+		MDSRequestRate: prometheus.NewDesc(fmt.Sprintf("%s_%s_mds_request_rate", namespace, subSystem), "Client requests per second served by the MDS rank",
+			mdsLabels, labels,
+		),
+		MDSDentries: prometheus.NewDesc(fmt.Sprintf("%s_%s_mds_dentries", namespace, subSystem), "No. of dentries cached by the MDS rank",
+			mdsLabels, labels,
+		),
+		MDSInodes: prometheus.NewDesc(fmt.Sprintf("%s_%s_mds_inodes", namespace, subSystem), "No. of inodes cached by the MDS rank",
+			mdsLabels, labels,
+		),
+		MDSCaps: prometheus.NewDesc(fmt.Sprintf("%s_%s_mds_caps", namespace, subSystem), "No. of capabilities held by clients of the MDS rank",
+			mdsLabels, labels,
+		),
+		PoolUsedBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_pool_used_bytes", namespace, subSystem), "Capacity of the filesystem pool that is currently under use",
+			[]string{"fs", "pool", "type"}, labels,
+		),
+		PoolAvailBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_pool_available_bytes", namespace, subSystem), "Free space for the filesystem pool",
+			[]string{"fs", "pool", "type"}, labels,
+		),
+	}
+}
+
+func (c *CephFSCollector) descriptorList() []*prometheus.Desc {
+	return []*prometheus.Desc{
+		c.MDSActive,
+		c.MDSStandbyReplay,
+		c.MDSUp,
+		c.MDSStandby,
+		c.MaxMDS,
+		c.MDSState,
+		c.Clients,
+		c.MDSRequestRate,
+		c.MDSDentries,
+		c.MDSInodes,
+		c.MDSCaps,
+		c.PoolUsedBytes,
+		c.PoolAvailBytes,
+	}
+}
+
+type cephFSDump struct {
+	Standbys []struct {
+		Name  string `json:"name"`
+		State string `json:"state"`
+	} `json:"standbys"`
+	Filesystems []struct {
+		MDSMap struct {
+			FSName string `json:"fs_name"`
+			MaxMDS int    `json:"max_mds"`
+			Info   map[string]struct {
+				Name  string `json:"name"`
+				Rank  int    `json:"rank"`
+				State string `json:"state"`
+			} `json:"info"`
+		} `json:"mdsmap"`
+	} `json:"filesystems"`
+}
+
+type cephFSStatus struct {
+	Clients []struct {
+		FS      string  `json:"fs"`
+		Clients float64 `json:"clients"`
+	} `json:"clients"`
+	MDSMap []struct {
+		Name  string      `json:"name"`
+		Rank  json.Number `json:"rank"`
+		State string      `json:"state"`
+		Rate  json.Number `json:"rate"`
+		DNS   float64     `json:"dns"`
+		Inos  float64     `json:"inos"`
+		Caps  float64     `json:"caps"`
+	} `json:"mdsmap"`
+	Pools []struct {
+		Name  string  `json:"name"`
+		Type  string  `json:"type"`
+		Used  float64 `json:"used"`
+		Avail float64 `json:"avail"`
+	} `json:"pools"`
+}
+
+func (c *CephFSCollector) collect(ch chan<- prometheus.Metric) error {
+	cmd := c.cephFSDumpCommand()
+	buf, _, err := c.conn.MonCommand(cmd)
+	if err != nil {
+		c.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return err
+	}
+
+	dump := &cephFSDump{}
+	if err := json.Unmarshal(buf, dump); err != nil {
+		return err
+	}
+
+	up := float64(len(dump.Standbys))
+	for _, fs := range dump.Filesystems {
+		for _, info := range fs.MDSMap.Info {
+			if strings.HasPrefix(info.State, "up:") {
+				up++
+			}
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.MDSUp, prometheus.GaugeValue, up)
+	ch <- prometheus.MustNewConstMetric(c.MDSStandby, prometheus.GaugeValue, float64(len(dump.Standbys)))
+
+	if len(dump.Filesystems) == 0 {
+		c.logger.Debug("no CephFS filesystems found")
+		return nil
+	}
+
+	for _, fs := range dump.Filesystems {
+		name := fs.MDSMap.FSName
+
+		var active, standbyReplay float64
+		for _, info := range fs.MDSMap.Info {
+			switch info.State {
+			case mdsStateActive:
+				active++
+			case mdsStateStandbyReplay:
+				standbyReplay++
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.MDSState, prometheus.GaugeValue, 1,
+				name, info.Name, strconv.Itoa(info.Rank), info.State)
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.MDSActive, prometheus.GaugeValue, active, name)
+		ch <- prometheus.MustNewConstMetric(c.MDSStandbyReplay, prometheus.GaugeValue, standbyReplay, name)
+		ch <- prometheus.MustNewConstMetric(c.MaxMDS, prometheus.GaugeValue, float64(fs.MDSMap.MaxMDS), name)
+
+		// JSON output of `fs status` is only available from Octopus onwards.
+		if !c.version.IsAtLeast(Octopus) {
+			continue
+		}
+
+		if err := c.collectFSStatus(name, ch); err != nil {
+			c.logger.WithError(err).WithField("fs", name).Error("error collecting CephFS status")
+		}
+	}
+
+	return nil
+}
+
+func (c *CephFSCollector) collectFSStatus(fs string, ch chan<- prometheus.Metric) error {
+	args := c.cephFSStatusCommand(fs)
+	buf, _, err := c.conn.MgrCommand(args)
+	if err != nil {
+		c.logger.WithError(err).WithField(
+			"args", string(bytes.Join(args, []byte(","))),
+		).Error("error executing mgr command")
+
+		return err
+	}
+
+	status := &cephFSStatus{}
+	if err := json.Unmarshal(buf, status); err != nil {
+		return err
+	}
+
+	for _, clients := range status.Clients {
+		ch <- prometheus.MustNewConstMetric(c.Clients, prometheus.GaugeValue, clients.Clients, clients.FS)
+	}
+
+	for _, mds := range status.MDSMap {
+		// standby daemons are listed without a rank
+		if mds.Rank == "" {
+			continue
+		}
+
+		rank := mds.Rank.String()
+
+		// the rate is reported as a string for ranks that aren't active
+		rate, err := mds.Rate.Float64()
+		if err != nil {
+			rate = 0
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.MDSRequestRate, prometheus.GaugeValue, rate, fs, mds.Name, rank)
+		ch <- prometheus.MustNewConstMetric(c.MDSDentries, prometheus.GaugeValue, mds.DNS, fs, mds.Name, rank)
+		ch <- prometheus.MustNewConstMetric(c.MDSInodes, prometheus.GaugeValue, mds.Inos, fs, mds.Name, rank)
+		ch <- prometheus.MustNewConstMetric(c.MDSCaps, prometheus.GaugeValue, mds.Caps, fs, mds.Name, rank)
+	}
+
+	for _, pool := range status.Pools {
+		ch <- prometheus.MustNewConstMetric(c.PoolUsedBytes, prometheus.GaugeValue, pool.Used, fs, pool.Name, pool.Type)
+		ch <- prometheus.MustNewConstMetric(c.PoolAvailBytes, prometheus.GaugeValue, pool.Avail, fs, pool.Name, pool.Type)
+	}
+
+	return nil
+}
+
+func (c *CephFSCollector) cephFSDumpCommand() []byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "fs dump",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		c.logger.WithError(err).Panic("error marshalling ceph fs dump")
+	}
+	return cmd
+}
+
+func (c *CephFSCollector) cephFSStatusCommand(fs string) [][]byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "fs status",
+		"fs":     fs,
+		"format": jsonFormat,
+	})
+	if err != nil {
+		c.logger.WithError(err).Panic("error marshalling ceph fs status")
+	}
+	return [][]byte{cmd}
+}
+
+// Describe sends the descriptors of each CephFSCollector related metric we
+// have defined to the provided prometheus channel.
+func (c *CephFSCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.descriptorList() {
+		ch <- metric
+	}
+}
+
+// Collect sends all the collected metrics to the provided prometheus channel.
+// It requires the caller to handle synchronization.
+func (c *CephFSCollector) Collect(ch chan<- prometheus.Metric) {
+	c.logger.Debug("collecting CephFS metrics")
+	if err := c.collect(ch); err != nil {
+		c.logger.WithError(err).Error("error collecting CephFS metrics")
+	}
+}
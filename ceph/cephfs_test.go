@@ -0,0 +1,179 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCephFSCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		version   *Version
+		fsDump    string
+		fsStatus  string
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:    "single filesystem with standby-replay",
+			version: Pacific,
+			fsDump: `
+{
+  "epoch": 42,
+  "standbys": [
+    {"gid": 4456, "name": "c", "rank": -1, "state": "up:standby"}
+  ],
+  "filesystems": [
+    {
+      "id": 1,
+      "mdsmap": {
+        "fs_name": "cephfs",
+        "max_mds": 1,
+        "in": [0],
+        "up": {"mds_0": 4123},
+        "info": {
+          "gid_4123": {"gid": 4123, "name": "a", "rank": 0, "state": "up:active"},
+          "gid_4234": {"gid": 4234, "name": "b", "rank": 0, "state": "up:standby-replay"}
+        },
+        "data_pools": [3],
+        "metadata_pool": 2
+      }
+    }
+  ]
+}`,
+			fsStatus: `
+{
+  "clients": [{"clients": 7, "fs": "cephfs"}],
+  "mds_version": [{"daemon": ["a", "b", "c"], "version": "ceph version 16.2.7"}],
+  "mdsmap": [
+    {"caps": 120, "dirs": 12, "dns": 200, "inos": 180, "name": "a", "rank": 0, "rate": 15.5, "state": "active"},
+    {"caps": 0, "dirs": 12, "dns": 200, "inos": 180, "name": "b", "rank": 0, "rate": "0", "state": "standby-replay"},
+    {"name": "c", "state": "standby"}
+  ],
+  "pools": [
+    {"avail": 1000, "id": 2, "name": "cephfs_metadata", "type": "metadata", "used": 20},
+    {"avail": 1000, "id": 3, "name": "cephfs_data", "type": "data", "used": 300}
+  ]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mds_up{cluster="ceph"} 3`),
+				regexp.MustCompile(`ceph_mds_standby{cluster="ceph"} 1`),
+				regexp.MustCompile(`ceph_fs_mds_active{cluster="ceph",fs="cephfs"} 1`),
+				regexp.MustCompile(`ceph_fs_mds_standby_replay{cluster="ceph",fs="cephfs"} 1`),
+				regexp.MustCompile(`ceph_fs_max_mds{cluster="ceph",fs="cephfs"} 1`),
+				regexp.MustCompile(`ceph_fs_mds_state{cluster="ceph",fs="cephfs",name="a",rank="0",state="up:active"} 1`),
+				regexp.MustCompile(`ceph_fs_mds_state{cluster="ceph",fs="cephfs",name="b",rank="0",state="up:standby-replay"} 1`),
+				regexp.MustCompile(`ceph_fs_clients{cluster="ceph",fs="cephfs"} 7`),
+				regexp.MustCompile(`ceph_fs_mds_request_rate{cluster="ceph",fs="cephfs",name="a",rank="0"} 15.5`),
+				regexp.MustCompile(`ceph_fs_mds_request_rate{cluster="ceph",fs="cephfs",name="b",rank="0"} 0`),
+				regexp.MustCompile(`ceph_fs_mds_dentries{cluster="ceph",fs="cephfs",name="a",rank="0"} 200`),
+				regexp.MustCompile(`ceph_fs_mds_inodes{cluster="ceph",fs="cephfs",name="a",rank="0"} 180`),
+				regexp.MustCompile(`ceph_fs_mds_caps{cluster="ceph",fs="cephfs",name="a",rank="0"} 120`),
+				regexp.MustCompile(`ceph_fs_pool_used_bytes{cluster="ceph",fs="cephfs",pool="cephfs_data",type="data"} 300`),
+				regexp.MustCompile(`ceph_fs_pool_available_bytes{cluster="ceph",fs="cephfs",pool="cephfs_metadata",type="metadata"} 1000`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`name="c",rank`),
+			},
+		},
+		{
+			name:    "nautilus has no fs status",
+			version: Nautilus,
+			fsDump: `
+{
+  "standbys": [],
+  "filesystems": [
+    {
+      "mdsmap": {
+        "fs_name": "cephfs",
+        "max_mds": 2,
+        "info": {
+          "gid_4123": {"name": "a", "rank": 0, "state": "up:active"},
+          "gid_4124": {"name": "b", "rank": 1, "state": "up:rejoin"}
+        }
+      }
+    }
+  ]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mds_up{cluster="ceph"} 2`),
+				regexp.MustCompile(`ceph_mds_standby{cluster="ceph"} 0`),
+				regexp.MustCompile(`ceph_fs_mds_active{cluster="ceph",fs="cephfs"} 1`),
+				regexp.MustCompile(`ceph_fs_max_mds{cluster="ceph",fs="cephfs"} 2`),
+				regexp.MustCompile(`ceph_fs_mds_state{cluster="ceph",fs="cephfs",name="b",rank="1",state="up:rejoin"} 1`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_fs_clients`),
+				regexp.MustCompile(`ceph_fs_pool_used_bytes`),
+			},
+		},
+		{
+			name:    "no filesystems",
+			version: Pacific,
+			fsDump:  `{"epoch": 1, "standbys": [], "filesystems": []}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mds_up{cluster="ceph"} 0`),
+				regexp.MustCompile(`ceph_mds_standby{cluster="ceph"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_fs_`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MonCommand", mock.Anything).Return([]byte(tt.fsDump), "", nil)
+			conn.On("MgrCommand", mock.Anything).Return([]byte(tt.fsStatus), "", nil)
+
+			collector := NewCephFSCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), Version: tt.version})
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				if !re.Match(buf) {
+					t.Errorf("expected %s to match\n", re.String())
+				}
+			}
+
+			for _, re := range tt.reUnmatch {
+				if re.Match(buf) {
+					t.Errorf("expected %s to not match\n", re.String())
+				}
+			}
+		})
+	}
+}
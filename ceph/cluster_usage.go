@@ -16,15 +16,97 @@ package ceph
 
 import (
 	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	cephNamespace = "ceph"
+	// DefaultNamespace prefixes the name of every metric, unless the
+	// exporter sets another Namespace.
+	DefaultNamespace = "ceph"
+
+	// minUsageHistorySpan is the fraction of the window the samples must
+	// span before a fill rate is estimated from them, so that a few samples
+	// taken seconds apart after a restart don't make for a wild projection.
+	minUsageHistorySpan = 0.25
 )
 
+// usageSample is the used capacity of the cluster at a point in time.
+type usageSample struct {
+	at   time.Time
+	used float64
+}
+
+// usageHistory keeps the used capacity samples of a cluster over a sliding
+// window, so that its fill rate can be estimated across scrapes.
+type usageHistory struct {
+	mu      sync.Mutex
+	window  time.Duration
+	samples []usageSample
+}
+
+func newUsageHistory(window time.Duration) *usageHistory {
+	return &usageHistory{window: window}
+}
+
+// add records a sample and drops the ones that have fallen out of the window.
+func (h *usageHistory) add(at time.Time, used float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples = append(h.samples, usageSample{at: at, used: used})
+
+	cutoff := at.Add(-h.window)
+	i := 0
+	for i < len(h.samples) && h.samples[i].at.Before(cutoff) {
+		i++
+	}
+	h.samples = h.samples[i:]
+}
+
+// rate returns the fill rate in bytes per second, as the slope of the least
+// squares fit over the samples in the window. It is false if there aren't
+// enough samples to tell, or if they span too little of the window yet.
+func (h *usageHistory) rate() (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.samples) < 2 {
+		return 0, false
+	}
+
+	span := h.samples[len(h.samples)-1].at.Sub(h.samples[0].at)
+	if span.Seconds() < h.window.Seconds()*minUsageHistorySpan {
+		return 0, false
+	}
+
+	var sumX, sumY float64
+	origin := h.samples[0].at
+	for _, s := range h.samples {
+		sumX += s.at.Sub(origin).Seconds()
+		sumY += s.used
+	}
+
+	n := float64(len(h.samples))
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, variance float64
+	for _, s := range h.samples {
+		dx := s.at.Sub(origin).Seconds() - meanX
+		cov += dx * (s.used - meanY)
+		variance += dx * dx
+	}
+
+	if variance == 0 {
+		return 0, false
+	}
+
+	return cov / variance, true
+}
+
 // A ClusterUsageCollector is used to gather all the global stats about a
 // given ceph cluster. It is sometimes essential to know how fast the cluster
 // is growing or shrinking as a whole in order to zero in on the cause. The
@@ -33,6 +115,7 @@ type ClusterUsageCollector struct {
 	conn    Conn
 	logger  *logrus.Logger
 	version *Version
+	history *usageHistory
 
 	// GlobalCapacity displays the total storage capacity of the cluster. This
 	// information is based on the actual no. of objects that are
@@ -45,6 +128,17 @@ type ClusterUsageCollector struct {
 	// AvailableCapacity shows the remaining capacity of the cluster that is
 	// left unallocated.
 	AvailableCapacity prometheus.Gauge
+
+	// FullnessRatio shows the fraction of the cluster capacity that is in
+	// use, i.e. the average fullness of the OSDs.
+	FullnessRatio prometheus.Gauge
+
+	// ProjectedDaysToFull shows how many days are left until the raw
+	// capacity of the cluster is used up at the fill rate seen over the
+	// history window, or -1 if the cluster isn't filling up or there isn't
+	// enough history yet. Ceph stops writes well before that, at the OSD
+	// full ratio.
+	ProjectedDaysToFull prometheus.Gauge
 }
 
 // NewClusterUsageCollector creates and returns the reference to
@@ -53,30 +147,44 @@ type ClusterUsageCollector struct {
 func NewClusterUsageCollector(exporter *Exporter) *ClusterUsageCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 
 	return &ClusterUsageCollector{
 		conn:    exporter.Conn,
 		logger:  exporter.Logger,
 		version: exporter.Version,
+		history: exporter.usageHistory,
 
 		GlobalCapacity: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace:   cephNamespace,
+			Namespace:   namespace,
 			Name:        "cluster_capacity_bytes",
 			Help:        "Total capacity of the cluster",
 			ConstLabels: labels,
 		}),
 		UsedCapacity: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace:   cephNamespace,
+			Namespace:   namespace,
 			Name:        "cluster_used_bytes",
 			Help:        "Capacity of the cluster currently in use",
 			ConstLabels: labels,
 		}),
 		AvailableCapacity: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace:   cephNamespace,
+			Namespace:   namespace,
 			Name:        "cluster_available_bytes",
 			Help:        "Available space within the cluster",
 			ConstLabels: labels,
 		}),
+		FullnessRatio: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "cluster_fullness_ratio",
+			Help:        "Fraction of the cluster capacity currently in use",
+			ConstLabels: labels,
+		}),
+		ProjectedDaysToFull: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "cluster_projected_days_to_full",
+			Help:        "Projected number of days until the raw capacity of the cluster is used up at the recent fill rate, ignoring the OSD full ratio; -1 if it isn't filling up or the history is too short",
+			ConstLabels: labels,
+		}),
 	}
 }
 
@@ -85,6 +193,8 @@ func (c *ClusterUsageCollector) metricsList() []prometheus.Metric {
 		c.GlobalCapacity,
 		c.UsedCapacity,
 		c.AvailableCapacity,
+		c.FullnessRatio,
+		c.ProjectedDaysToFull,
 	}
 }
 
@@ -116,6 +226,19 @@ func (c *ClusterUsageCollector) collect() error {
 	c.UsedCapacity.Set(stats.Stats.TotalUsedBytes)
 	c.AvailableCapacity.Set(stats.Stats.TotalAvailBytes)
 
+	if stats.Stats.TotalBytes > 0 {
+		c.FullnessRatio.Set(stats.Stats.TotalUsedBytes / stats.Stats.TotalBytes)
+	}
+
+	c.ProjectedDaysToFull.Set(-1)
+	if c.history != nil {
+		c.history.add(time.Now(), stats.Stats.TotalUsedBytes)
+
+		if rate, ok := c.history.rate(); ok && rate > 0 {
+			c.ProjectedDaysToFull.Set(stats.Stats.TotalAvailBytes / rate / (24 * 60 * 60))
+		}
+	}
+
 	return nil
 }
 
@@ -16,13 +16,16 @@ package ceph
 
 import (
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -46,6 +49,8 @@ func TestClusterUsage(t *testing.T) {
 				regexp.MustCompile(`ceph_cluster_capacity_bytes{cluster="ceph"} 10`),
 				regexp.MustCompile(`ceph_cluster_used_bytes{cluster="ceph"} 6`),
 				regexp.MustCompile(`ceph_cluster_available_bytes{cluster="ceph"} 4`),
+				regexp.MustCompile(`ceph_cluster_fullness_ratio{cluster="ceph"} 0.6`),
+				regexp.MustCompile(`ceph_cluster_projected_days_to_full{cluster="ceph"} -1`),
 			},
 			reUnmatch: []*regexp.Regexp{},
 		},
@@ -157,3 +162,85 @@ func TestClusterUsage(t *testing.T) {
 		}()
 	}
 }
+
+func TestUsageHistoryRate(t *testing.T) {
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name    string
+		window  time.Duration
+		samples []float64 // one per hour from start
+		rate    float64
+		ok      bool
+	}{
+		{
+			name:    "single sample",
+			window:  24 * time.Hour,
+			samples: []float64{100},
+		},
+		{
+			// three hours are less than a quarter of the window
+			name:    "too short",
+			window:  24 * time.Hour,
+			samples: []float64{0, 3600, 7200, 10800},
+		},
+		{
+			name:    "growing",
+			window:  8 * time.Hour,
+			samples: []float64{0, 3600, 7200, 10800},
+			rate:    1,
+			ok:      true,
+		},
+		{
+			name:    "shrinking",
+			window:  8 * time.Hour,
+			samples: []float64{10800, 7200, 3600, 0},
+			rate:    -1,
+			ok:      true,
+		},
+		{
+			// only the last two samples are within the window
+			name:    "window",
+			window:  time.Hour,
+			samples: []float64{0, 0, 0, 7200},
+			rate:    2,
+			ok:      true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newUsageHistory(tt.window)
+			for i, used := range tt.samples {
+				h.add(start.Add(time.Duration(i)*time.Hour), used)
+			}
+
+			rate, ok := h.rate()
+			require.Equal(t, tt.ok, ok)
+			require.True(t, math.Abs(tt.rate-rate) < 1e-9, "rate %v", rate)
+		})
+	}
+}
+
+func TestClusterUsageProjectedDaysToFull(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.Anything).Return(
+		[]byte(`{"stats": {"total_bytes": 10, "total_used_bytes": 6, "total_avail_bytes": 4}}`), "", nil,
+	)
+
+	// one byte written in the last day
+	history := newUsageHistory(48 * time.Hour)
+	history.add(time.Now().Add(-24*time.Hour), 5)
+
+	collector := NewClusterUsageCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), usageHistory: history})
+	require.NoError(t, collector.collect())
+
+	days := testutil.ToFloat64(collector.ProjectedDaysToFull)
+	require.True(t, math.Abs(days-4) < 0.01, "days to full %v", days)
+
+	// a byte written in the last minute is no ground for a projection
+	history = newUsageHistory(48 * time.Hour)
+	history.add(time.Now().Add(-time.Minute), 5)
+
+	collector = NewClusterUsageCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), usageHistory: history})
+	require.NoError(t, collector.collect())
+	require.Equal(t, float64(-1), testutil.ToFloat64(collector.ProjectedDaysToFull))
+}
@@ -25,6 +25,16 @@ type Conn interface {
 	GetPoolStats(string) (*PoolStat, error)
 }
 
+// DaemonConn is optionally implemented by a Conn that can also send commands
+// to the admin socket of a daemon running on the exporter's host, as
+// `ceph daemon` does. Daemons are named as in `osd.0` or `mon.a`. It gives
+// access to per-daemon counters, such as the full `perf dump`, that are not
+// available through MonCommand. The Conn of an exporter implements it when
+// admin sockets are configured, which collectors check with a type assertion.
+type DaemonConn interface {
+	DaemonCommand(daemon string, args []byte) ([]byte, error)
+}
+
 // PoolStats contains data for a single pool.
 // We currently only use one field but may add more from co-ceph/rados.PoolStat
 type PoolStat struct {
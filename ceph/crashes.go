@@ -17,6 +17,8 @@ package ceph
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -24,8 +26,18 @@ import (
 
 var (
 	statusNames = map[bool]string{true: "new", false: "archived"}
+
+	// crashTimeLayouts are the layouts of the crash timestamps, which are
+	// printed with a space before Octopus.
+	crashTimeLayouts = []string{
+		"2006-01-02T15:04:05.999999Z",
+		"2006-01-02 15:04:05.999999Z",
+	}
 )
 
+// crashRecentWindow is how far back crashes count as recent.
+const crashRecentWindow = 24 * time.Hour
+
 // CrashesCollector collects information on how many crash reports are currently open.
 // These reports are counted by daemon/client name, and by status (new or archived).
 // This is NOT the same as new_crash_reports, that only counts new reports in the past
@@ -35,25 +47,37 @@ type CrashesCollector struct {
 	logger  *logrus.Logger
 	version *Version
 
-	crashReportsDesc *prometheus.Desc
+	// now returns the time recent crashes are counted back from.
+	now func() time.Time
+
+	crashReportsDesc       *prometheus.Desc
+	recentCrashReportsDesc *prometheus.Desc
 }
 
 // NewCrashesCollector creates a new CrashesCollector instance
 func NewCrashesCollector(exporter *Exporter) *CrashesCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 
 	collector := &CrashesCollector{
 		conn:    exporter.Conn,
 		logger:  exporter.Logger,
 		version: exporter.Version,
+		now:     time.Now,
 
 		crashReportsDesc: prometheus.NewDesc(
-			fmt.Sprintf("%s_crash_reports", cephNamespace),
+			fmt.Sprintf("%s_crash_reports", namespace),
 			"Count of crashes reports per daemon, according to `ceph crash ls`",
 			[]string{"entity", "hostname", "status"},
 			labels,
 		),
+		recentCrashReportsDesc: prometheus.NewDesc(
+			fmt.Sprintf("%s_crash_reports_recent", namespace),
+			"Count of crash reports of the last 24 hours per daemon type, according to `ceph crash ls`",
+			[]string{"daemon_type", "status"},
+			labels,
+		),
 	}
 
 	return collector
@@ -65,49 +89,84 @@ type crashEntry struct {
 	isNew    bool
 }
 
+// recentCrashEntry groups the recent crashes by type of daemon, such as osd
+// or client.
+type recentCrashEntry struct {
+	daemonType string
+	isNew      bool
+}
+
 type cephCrashLs struct {
-	Entity   string `json:"entity_name"`
-	Hostname string `json:"utsname_hostname"`
-	Archived string `json:"archived"`
+	Entity    string `json:"entity_name"`
+	Hostname  string `json:"utsname_hostname"`
+	Archived  string `json:"archived"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Time returns when the crash happened, and false if its timestamp can't be
+// parsed.
+func (c cephCrashLs) Time() (time.Time, bool) {
+	for _, layout := range crashTimeLayouts {
+		if t, err := time.Parse(layout, c.Timestamp); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
 }
 
-// getCrashLs runs the 'ceph crash ls' command and process its results
-func (c *CrashesCollector) getCrashLs() (map[crashEntry]int, error) {
+// getCrashLs runs the 'ceph crash ls' command and process its results. The
+// recent crashes are counted for every daemon type and status that has any
+// crash listed, so that they go back to 0 rather than disappear.
+func (c *CrashesCollector) getCrashLs() (map[crashEntry]int, map[recentCrashEntry]int, error) {
 	crashes := make(map[crashEntry]int)
+	recent := make(map[recentCrashEntry]int)
 
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "crash ls",
 		"format": "json",
 	})
 	if err != nil {
-		return crashes, err
+		return crashes, recent, err
 	}
 
 	buf, _, err := c.conn.MonCommand(cmd)
 	if err != nil {
-		return crashes, err
+		return crashes, recent, err
 	}
 
 	var crashData []cephCrashLs
 	if err = json.Unmarshal(buf, &crashData); err != nil {
-		return crashes, err
+		return crashes, recent, err
 	}
 
+	since := c.now().Add(-crashRecentWindow)
 	for _, crash := range crashData {
-		crashes[crashEntry{crash.Entity, crash.Hostname, len(crash.Archived) == 0}]++
+		isNew := len(crash.Archived) == 0
+		crashes[crashEntry{crash.Entity, crash.Hostname, isNew}]++
+
+		// entities are named after their daemon type, e.g. osd.3
+		daemonType := strings.SplitN(crash.Entity, ".", 2)[0]
+		entry := recentCrashEntry{daemonType, isNew}
+		if _, ok := recent[entry]; !ok {
+			recent[entry] = 0
+		}
+		if t, ok := crash.Time(); ok && t.After(since) {
+			recent[entry]++
+		}
 	}
 
-	return crashes, nil
+	return crashes, recent, nil
 }
 
 // Describe provides the metrics descriptions to Prometheus
 func (c *CrashesCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.crashReportsDesc
+	ch <- c.recentCrashReportsDesc
 }
 
 // Collect sends all the collected metrics Prometheus.
 func (c *CrashesCollector) Collect(ch chan<- prometheus.Metric) {
-	crashes, err := c.getCrashLs()
+	crashes, recent, err := c.getCrashLs()
 	if err != nil {
 		c.logger.WithError(err).Error("failed to run 'ceph crash ls'")
 	}
@@ -122,4 +181,14 @@ func (c *CrashesCollector) Collect(ch chan<- prometheus.Metric) {
 			statusNames[crash.isNew],
 		)
 	}
+
+	for crash, count := range recent {
+		ch <- prometheus.MustNewConstMetric(
+			c.recentCrashReportsDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			crash.daemonType,
+			statusNames[crash.isNew],
+		)
+	}
 }
@@ -19,10 +19,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -211,3 +214,50 @@ func TestCrashesCollector(t *testing.T) {
 		)
 	}
 }
+
+func TestCrashesCollectorRecent(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.Anything).Return([]byte(`
+[
+	{
+		"entity_name": "osd.0",
+		"utsname_hostname": "test-ceph-server.company.example",
+		"timestamp": "2022-02-03 04:05:45.419226Z",
+		"crash_id": "2022-02-03_04:05:45.419226Z_11c639af-5eb2-4a29-91aa-20120218891a"
+	},
+	{
+		"entity_name": "osd.1",
+		"utsname_hostname": "test-ceph-server.company.example",
+		"timestamp": "2022-02-01 21:02:46.687015Z",
+		"crash_id": "2022-02-01_21:02:46.687015Z_0de8b741-b323-4f63-828a-e460294e28b9"
+	},
+	{
+		"entity_name": "client.admin",
+		"utsname_hostname": "test-ceph-server.company.example",
+		"timestamp": "2022-02-03T10:00:00.000000Z",
+		"archived": "2022-02-03 11:00:00.000000",
+		"crash_id": "2022-02-03T10:00:00.000000Z_d6513591-c16b-472f-8d40-5a143b28837d"
+	},
+	{
+		"entity_name": "mgr.mgr-node-01",
+		"utsname_hostname": "test-ceph-server.company.example",
+		"timestamp": "2022-01-01 00:00:00.000000Z",
+		"archived": "2022-01-02 00:00:00.000000",
+		"crash_id": "2022-01-01_00:00:00.000000Z_5e0a5c2b-0b59-4a8e-9b4a-1f0e2d3c4b5a"
+	}
+]`), "", nil)
+
+	collector := NewCrashesCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), Version: Pacific})
+	collector.now = func() time.Time {
+		return time.Date(2022, 2, 3, 12, 0, 0, 0, time.UTC)
+	}
+
+	expected := `
+# HELP ceph_crash_reports_recent Count of crash reports of the last 24 hours per daemon type, according to ` + "`ceph crash ls`" + `
+# TYPE ceph_crash_reports_recent gauge
+ceph_crash_reports_recent{cluster="ceph",daemon_type="client",status="archived"} 1
+ceph_crash_reports_recent{cluster="ceph",daemon_type="mgr",status="archived"} 0
+ceph_crash_reports_recent{cluster="ceph",daemon_type="osd",status="new"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "ceph_crash_reports_recent"))
+}
@@ -0,0 +1,159 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// deviceTimeLayout is the layout of the life expectancy times of the devices,
+// given in UTC.
+const deviceTimeLayout = "2006-01-02T15:04:05.999999"
+
+// DeviceHealthCollector reports the health of the disks backing the daemons,
+// as predicted by the devicehealth mgr module from their SMART data.
+type DeviceHealthCollector struct {
+	conn    Conn
+	logger  *logrus.Logger
+	version *Version
+
+	// now returns the time life expectancies are counted from.
+	now func() time.Time
+
+	// LifeExpectancy shows how long a device is expected to keep working,
+	// at the lower end of the predicted range. Devices without a prediction
+	// are left out.
+	LifeExpectancy *prometheus.Desc
+
+	// WearLevel shows the fraction of its endurance a device has used up.
+	// Devices that don't report it are left out.
+	WearLevel *prometheus.Desc
+}
+
+// NewDeviceHealthCollector creates a new DeviceHealthCollector instance.
+func NewDeviceHealthCollector(exporter *Exporter) *DeviceHealthCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return &DeviceHealthCollector{
+		conn:    exporter.Conn,
+		logger:  exporter.Logger,
+		version: exporter.Version,
+		now:     time.Now,
+
+		LifeExpectancy: prometheus.NewDesc(
+			fmt.Sprintf("%s_device_life_expectancy_seconds", namespace),
+			"Time until the device is predicted to fail, at the earliest",
+			[]string{"device", "host"},
+			labels,
+		),
+		WearLevel: prometheus.NewDesc(
+			fmt.Sprintf("%s_device_wear_level", namespace),
+			"Fraction of the endurance of the device used up",
+			[]string{"device", "host"},
+			labels,
+		),
+	}
+}
+
+type cephDevice struct {
+	DevID    string `json:"devid"`
+	Location []struct {
+		Host string `json:"host"`
+	} `json:"location"`
+	WearLevel         *float64 `json:"wear_level"`
+	LifeExpectancyMin string   `json:"life_expectancy_min"`
+}
+
+// Host returns the host the device is attached to, if it is known.
+func (d cephDevice) Host() string {
+	if len(d.Location) == 0 {
+		return ""
+	}
+	return d.Location[0].Host
+}
+
+func (c *DeviceHealthCollector) getDevices() ([]cephDevice, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "device ls",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := c.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []cephDevice
+	if err := json.Unmarshal(buf, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (c *DeviceHealthCollector) collect(ch chan<- prometheus.Metric) error {
+	// devices are tracked from Nautilus on; without a known version, try
+	// anyway
+	if c.version != nil && !c.version.IsAtLeast(Nautilus) {
+		return nil
+	}
+
+	devices, err := c.getDevices()
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	for _, device := range devices {
+		if device.LifeExpectancyMin != "" {
+			lifeExpectancy, err := time.Parse(deviceTimeLayout, device.LifeExpectancyMin)
+			if err != nil {
+				c.logger.WithError(err).WithField("device", device.DevID).Warn("error parsing device life expectancy")
+			} else {
+				ch <- prometheus.MustNewConstMetric(c.LifeExpectancy, prometheus.GaugeValue, lifeExpectancy.Sub(now).Seconds(), device.DevID, device.Host())
+			}
+		}
+
+		if device.WearLevel != nil {
+			ch <- prometheus.MustNewConstMetric(c.WearLevel, prometheus.GaugeValue, *device.WearLevel, device.DevID, device.Host())
+		}
+	}
+
+	return nil
+}
+
+// Describe sends the descriptors of the DeviceHealthCollector metrics to the
+// provided channel.
+func (c *DeviceHealthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.LifeExpectancy
+	ch <- c.WearLevel
+}
+
+// Collect sends the health of the devices to the provided channel.
+func (c *DeviceHealthCollector) Collect(ch chan<- prometheus.Metric) {
+	c.logger.Debug("collecting device health metrics")
+	if err := c.collect(ch); err != nil {
+		c.logger.WithError(err).Error("error collecting device health metrics")
+	}
+}
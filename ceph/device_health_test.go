@@ -0,0 +1,120 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceHealthCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		version            *Version
+		devices            string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:    "predicted and unknown health",
+			version: Pacific,
+			devices: `
+[
+	{
+		"devid": "ST4000NM0035-1V4107_ZC11CYEX",
+		"location": [{"host": "ceph-node01", "dev": "sda", "path": "/dev/disk/by-path/pci-0000:00:1f.2-ata-1"}],
+		"daemons": ["osd.0"],
+		"life_expectancy_min": "2022-03-20T12:00:00.000000",
+		"life_expectancy_max": "2022-04-10T12:00:00.000000",
+		"life_expectancy_stamp": "2022-03-10T00:00:00.000000"
+	},
+	{
+		"devid": "SAMSUNG_MZ7LH960HAJR-00005_S45NNA0M123456",
+		"location": [{"host": "ceph-node01", "dev": "sdb", "path": "/dev/disk/by-path/pci-0000:00:1f.2-ata-2"}],
+		"daemons": ["osd.1"],
+		"wear_level": 0.07
+	},
+	{
+		"devid": "QEMU_HARDDISK_QM00003",
+		"location": [],
+		"daemons": ["mon.a"]
+	}
+]`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_device_life_expectancy_seconds{cluster="ceph",device="ST4000NM0035-1V4107_ZC11CYEX",host="ceph-node01"} 864000`),
+				regexp.MustCompile(`ceph_device_wear_level{cluster="ceph",device="SAMSUNG_MZ7LH960HAJR-00005_S45NNA0M123456",host="ceph-node01"} 0.07`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_device_life_expectancy_seconds{cluster="ceph",device="SAMSUNG`),
+				regexp.MustCompile(`ceph_device_wear_level{cluster="ceph",device="ST4000`),
+				regexp.MustCompile(`QEMU_HARDDISK_QM00003`),
+			},
+		},
+		{
+			name:    "unknown version",
+			devices: `[{"devid": "ST4000NM0035-1V4107_ZC11CYEX", "location": [{"host": "ceph-node01"}], "wear_level": 0.5}]`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_device_wear_level{cluster="ceph",device="ST4000NM0035-1V4107_ZC11CYEX",host="ceph-node01"} 0.5`),
+			},
+		},
+		{
+			name:    "luminous",
+			version: &Version{Major: 12, Minor: 2, Patch: 13},
+			devices: `[{"devid": "ST4000NM0035-1V4107_ZC11CYEX", "wear_level": 0.5}]`,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_device_`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MgrCommand", mock.Anything).Return([]byte(tt.devices), "", nil)
+
+			collector := NewDeviceHealthCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), Version: tt.version})
+			collector.now = func() time.Time {
+				return time.Date(2022, 3, 10, 12, 0, 0, 0, time.UTC)
+			}
+
+			reg := prometheus.NewRegistry()
+			require.NoError(t, reg.Register(collector))
+
+			server := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), re.String())
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf), re.String())
+			}
+		})
+	}
+}
@@ -15,69 +15,441 @@
 package ceph
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Jeffail/gabs"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
+// Names of the collectors that can be disabled through NewExporter.
+const (
+	ClusterUsageCollectorName    = "cluster_usage"
+	PoolUsageCollectorName       = "pool_usage"
+	PoolInfoCollectorName        = "pool_info"
+	ClusterHealthCollectorName   = "health"
+	MonitorCollectorName         = "monitors"
+	MgrCollectorName             = "mgr"
+	BalancerCollectorName        = "balancer"
+	OSDCollectorName             = "osd"
+	ScrubCollectorName           = "scrub"
+	CrashesCollectorName         = "crashes"
+	DeviceHealthCollectorName    = "device_health"
+	CephFSCollectorName          = "cephfs"
+	RbdMirrorStatusCollectorName = "rbd_mirror"
+	RGWCollectorName             = "rgw"
+)
+
+// CollectorNames lists every collector name known to the exporter.
+var CollectorNames = []string{
+	ClusterUsageCollectorName,
+	PoolUsageCollectorName,
+	PoolInfoCollectorName,
+	ClusterHealthCollectorName,
+	MonitorCollectorName,
+	MgrCollectorName,
+	BalancerCollectorName,
+	OSDCollectorName,
+	ScrubCollectorName,
+	CrashesCollectorName,
+	DeviceHealthCollectorName,
+	CephFSCollectorName,
+	RbdMirrorStatusCollectorName,
+	RGWCollectorName,
+}
+
 // Exporter wraps all the ceph collectors and provides a single global
 // exporter to extracts metrics out of. It also ensures that the collection
 // is done in a thread-safe manner, the necessary requirement stated by
 // prometheus. It also implements a prometheus.Collector interface in order
 // to register it correctly.
 type Exporter struct {
-	mu        sync.Mutex
-	Conn      Conn
-	Cluster   string
-	Config    string
-	User      string
-	RgwMode   int
-	RbdMirror bool
-	Logger    *logrus.Logger
-	Version   *Version
+	mu               sync.Mutex
+	refreshMu        sync.Mutex
+	Conn             Conn
+	Cluster          string
+	Namespace        string
+	Config           string
+	User             string
+	RgwMode          int
+	RadosgwAdminPath string
+	RGWTimeout       time.Duration
+	RbdMirrorPools   []string
+	RGWInstances     []RGWInstance
+	RGWUserMetrics   bool
+	CollectTimeout   time.Duration
+	CacheTTL         time.Duration
+	RefreshInterval  time.Duration
+	VersionTTL       time.Duration
+	CapacityWindow   time.Duration
+	Disabled         map[string]bool
+	RelabelRules     []RelabelRule
+	RbdMirror        bool
+	Logger           *logrus.Logger
+	Version          *Version
+
+	// versionAt is when Version was last queried from the cluster; it is
+	// queried again once VersionTTL has passed.
+	versionAt time.Time
+
+	// versionDetectFailed is whether the last query of Version failed, in
+	// which case the collectors run with the previous Version, or none at all
+	// if it was never known.
+	versionDetectFailed bool
+
+	// fsid is the cluster's fsid, queried once since it never changes.
+	fsid string
+
+	// knownPools holds the pools found by the last collection, so that pools
+	// removed since then can be reported.
+	knownPools *poolSet
+
+	// osdLabels caches the CRUSH location and device class of the OSDs.
+	osdLabels *osdLabelCache
+
+	// usageHistory holds the used capacity seen over CapacityWindow, to
+	// project when the cluster will be full.
+	usageHistory *usageHistory
+
+	// rgwBackground holds the RGW collectors of RGWModeBackground, one per
+	// instance. They collect on their own until the exporter is closed, and
+	// every collection serves what they collected last.
+	rgwBackground []*RGWCollector
+
+	// ctx is cancelled once the collection the collectors were created for
+	// gives up on them.
+	ctx context.Context
+
+	// cachedMetrics holds the metrics gathered by the last collection, to be
+	// replayed until CacheTTL has passed since cachedAt, or until the next
+	// background refresh.
+	cachedMetrics []prometheus.Metric
+	cachedAt      time.Time
+
+	// scrapeErrors counts the errors logged by each collector, by collector
+	// name, since the exporter was created. The collectors are created anew
+	// for every collection and only count the errors of that collection, so
+	// the running total has to be kept here. It is only used by collect,
+	// which runs under refreshMu, and is not copied to the scoped exporters
+	// handed to the collectors.
+	scrapeErrors map[string]float64
+
+	// stop is closed by Close to end the background refresh.
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// ExporterOptions holds the settings of an Exporter besides its connection and
+// cluster label. Settings left at their zero value are disabled or use their
+// defaults.
+type ExporterOptions struct {
+	// Namespace prefixes the metric names, or DefaultNamespace if empty.
+	Namespace string
+
+	// Config and User are the Ceph config file and user that radosgw-admin
+	// runs with, unless RGWInstances are given.
+	Config string
+	User   string
+
+	// RgwMode is one of RGWModeDisabled, RGWModeForeground or
+	// RGWModeBackground. An invalid mode is logged once and treated as
+	// RGWModeDisabled.
+	RgwMode int
+
+	// RadosgwAdminPath is the radosgw-admin binary, or
+	// DefaultRadosgwAdminPath if empty.
+	RadosgwAdminPath string
+
+	// RGWTimeout is the time after which a radosgw-admin command is killed.
+	RGWTimeout time.Duration
+
+	// RbdMirrorPools are the pools whose rbd-mirror status is reported.
+	RbdMirrorPools []string
+
+	// RGWInstances are the RGW instances collected from, or the cluster's own
+	// user and config if there are none.
+	RGWInstances []RGWInstance
+
+	// RGWUserMetrics collects the quota and usage of every RGW user, which
+	// takes two radosgw-admin commands per user.
+	RGWUserMetrics bool
+
+	// CollectTimeout is the time budget of a whole collection.
+	CollectTimeout time.Duration
+
+	// CacheTTL is the time for which the metrics of a collection are replayed
+	// instead of collecting again.
+	CacheTTL time.Duration
+
+	// RefreshInterval collects in the background at that interval, and
+	// scrapes are served the metrics of the last refresh.
+	RefreshInterval time.Duration
+
+	// VersionTTL is the time for which the Ceph version is cached, or it is
+	// queried on every collection if zero.
+	VersionTTL time.Duration
+
+	// CapacityWindow is the window over which the fill rate used to project
+	// when the cluster will be full is estimated.
+	CapacityWindow time.Duration
+
+	// DisabledCollectors are the names of the collectors that are never
+	// run; unknown names are logged and otherwise ignored.
+	DisabledCollectors []string
+
+	// RelabelRules are applied to every metric on its way out of Collect.
+	RelabelRules []RelabelRule
 }
 
 // NewExporter returns an initialized *Exporter
 // We can choose to enable a collector to extract stats out of by adding it to the list of collectors.
-func NewExporter(conn Conn, cluster string, config string, user string, rgwMode int, logger *logrus.Logger) *Exporter {
-	return &Exporter{
-		Conn:    conn,
-		Cluster: cluster,
-		Config:  config,
-		User:    user,
-		RgwMode: rgwMode,
-		Logger:  logger,
+func NewExporter(conn Conn, cluster string, opts ExporterOptions, logger *logrus.Logger) *Exporter {
+	disabled := make(map[string]bool)
+	for _, name := range opts.DisabledCollectors {
+		if !isCollectorName(name) {
+			logger.WithField("collector", name).Warn("ignoring unknown collector name")
+			continue
+		}
+		disabled[name] = true
+	}
+
+	rgwMode := opts.RgwMode
+	if !isRGWMode(rgwMode) {
+		logger.WithField("RgwMode", rgwMode).Warn("RGW collector disabled due to invalid mode")
+		rgwMode = RGWModeDisabled
 	}
+
+	exporter := &Exporter{
+		Conn:             conn,
+		Cluster:          cluster,
+		Namespace:        opts.Namespace,
+		Config:           opts.Config,
+		User:             opts.User,
+		RgwMode:          rgwMode,
+		RadosgwAdminPath: opts.RadosgwAdminPath,
+		RGWTimeout:       opts.RGWTimeout,
+		RbdMirrorPools:   opts.RbdMirrorPools,
+		RGWInstances:     opts.RGWInstances,
+		RGWUserMetrics:   opts.RGWUserMetrics,
+		CollectTimeout:   opts.CollectTimeout,
+		CacheTTL:         opts.CacheTTL,
+		RefreshInterval:  opts.RefreshInterval,
+		VersionTTL:       opts.VersionTTL,
+		CapacityWindow:   opts.CapacityWindow,
+		Disabled:         disabled,
+		RelabelRules:     opts.RelabelRules,
+		Logger:           logger,
+		knownPools:       newPoolSet(),
+		usageHistory:     newUsageHistory(opts.CapacityWindow),
+		osdLabels:        &osdLabelCache{},
+		stop:             make(chan struct{}),
+	}
+
+	if exporter.RefreshInterval > 0 {
+		go exporter.backgroundRefresh()
+	}
+
+	if exporter.RgwMode == RGWModeBackground && !exporter.Disabled[RGWCollectorName] {
+		for _, instance := range exporter.rgwInstances() {
+			rgw := NewRGWInstanceCollector(exporter, instance, true)
+			exporter.rgwBackground = append(exporter.rgwBackground, rgw)
+			go rgw.backgroundCollect(exporter.stop)
+		}
+	}
+
+	return exporter
+}
+
+// rgwInstances returns the RGW instances to collect from, which are the
+// cluster's own user and config if none are configured.
+func (exporter *Exporter) rgwInstances() []RGWInstance {
+	if len(exporter.RGWInstances) == 0 {
+		return []RGWInstance{{User: exporter.User, Config: exporter.Config}}
+	}
+	return exporter.RGWInstances
+}
+
+// isRGWMode returns whether mode is one of RGWModeDisabled, RGWModeForeground
+// or RGWModeBackground.
+func isRGWMode(mode int) bool {
+	switch mode {
+	case RGWModeDisabled, RGWModeForeground, RGWModeBackground:
+		return true
+	}
+	return false
+}
+
+// metricNamespace returns the prefix of the metric names, which defaults to
+// DefaultNamespace.
+func (exporter *Exporter) metricNamespace() string {
+	if exporter.Namespace == "" {
+		return DefaultNamespace
+	}
+	return exporter.Namespace
 }
 
-func (exporter *Exporter) getCollectors() []prometheus.Collector {
-	standardCollectors := []prometheus.Collector{
-		NewClusterUsageCollector(exporter),
-		NewPoolUsageCollector(exporter),
-		NewPoolInfoCollector(exporter),
-		NewClusterHealthCollector(exporter),
-		NewMonitorCollector(exporter),
-		NewOSDCollector(exporter),
-		NewCrashesCollector(exporter),
+func isCollectorName(name string) bool {
+	for _, n := range CollectorNames {
+		if n == name {
+			return true
+		}
 	}
+	return false
+}
+
+// namedCollector pairs a collector with the name it is reported under in the
+// exporter's own metrics, and counts the errors it logs while collecting.
+// Cancelling it stops the commands the collector is still running.
+type namedCollector struct {
+	prometheus.Collector
+	name   string
+	errors *errorCountHook
+	cancel context.CancelFunc
+}
+
+// errorCountHook is a logrus hook counting the entries logged at error level
+// or above.
+type errorCountHook struct {
+	count int64
+}
+
+func (h *errorCountHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+func (h *errorCountHook) Fire(*logrus.Entry) error {
+	atomic.AddInt64(&h.count, 1)
+	return nil
+}
+
+func (h *errorCountHook) Count() int64 {
+	return atomic.LoadInt64(&h.count)
+}
+
+// newNamedCollector builds a collector through newCollector, handing it a copy
+// of the exporter whose logger counts the errors logged by that collector
+// alone.
+func (exporter *Exporter) newNamedCollector(ctx context.Context, name string, newCollector func(*Exporter) prometheus.Collector) namedCollector {
+	ctx, cancel := context.WithCancel(ctx)
+
+	hook := &errorCountHook{}
+
+	hooks := make(logrus.LevelHooks)
+	for level, levelHooks := range exporter.Logger.Hooks {
+		hooks[level] = append([]logrus.Hook{}, levelHooks...)
+	}
+	hooks.Add(hook)
+
+	logger := &logrus.Logger{
+		Out:          exporter.Logger.Out,
+		Hooks:        hooks,
+		Formatter:    exporter.Logger.Formatter,
+		ReportCaller: exporter.Logger.ReportCaller,
+		Level:        exporter.Logger.GetLevel(),
+		ExitFunc:     exporter.Logger.ExitFunc,
+	}
+
+	scoped := &Exporter{
+		Conn:             exporter.Conn,
+		Cluster:          exporter.Cluster,
+		Namespace:        exporter.Namespace,
+		Config:           exporter.Config,
+		User:             exporter.User,
+		RgwMode:          exporter.RgwMode,
+		RadosgwAdminPath: exporter.RadosgwAdminPath,
+		RGWTimeout:       exporter.RGWTimeout,
+		RbdMirrorPools:   exporter.RbdMirrorPools,
+		RGWInstances:     exporter.RGWInstances,
+		RGWUserMetrics:   exporter.RGWUserMetrics,
+		RbdMirror:        exporter.RbdMirror,
+		Logger:           logger,
+		Version:          exporter.Version,
+		knownPools:       exporter.knownPools,
+		usageHistory:     exporter.usageHistory,
+		osdLabels:        exporter.osdLabels,
+		ctx:              ctx,
+	}
+
+	return namedCollector{
+		Collector: newCollector(scoped),
+		name:      name,
+		errors:    hook,
+		cancel:    cancel,
+	}
+}
+
+// collectContext returns the context bounding the commands run by the
+// collectors created from the exporter.
+func (exporter *Exporter) collectContext() context.Context {
+	if exporter.ctx == nil {
+		return context.Background()
+	}
+	return exporter.ctx
+}
+
+func (exporter *Exporter) getCollectors(ctx context.Context) []namedCollector {
+	standardCollectors := []namedCollector{}
+
+	// collectors are disabled by name, but may be reported under another one
+	addAs := func(name string, reportedName string, newCollector func(*Exporter) prometheus.Collector) {
+		if !exporter.Disabled[name] {
+			standardCollectors = append(standardCollectors, exporter.newNamedCollector(ctx, reportedName, newCollector))
+		}
+	}
+	add := func(name string, newCollector func(*Exporter) prometheus.Collector) {
+		addAs(name, name, newCollector)
+	}
+
+	// background RGW collectors are created once by NewExporter and reused
+	addRGW := func(background bool) {
+		for i, instance := range exporter.rgwInstances() {
+			instance := instance
+			name := RGWCollectorName
+			if instance.Zone != "" {
+				name += "/" + instance.Zone
+			}
+
+			if !background {
+				addAs(RGWCollectorName, name, func(e *Exporter) prometheus.Collector {
+					return NewRGWInstanceCollector(e, instance, false)
+				})
+			} else if i < len(exporter.rgwBackground) {
+				rgw := exporter.rgwBackground[i]
+				addAs(RGWCollectorName, name, func(*Exporter) prometheus.Collector { return rgw })
+			}
+		}
+	}
+
+	add(ClusterUsageCollectorName, func(e *Exporter) prometheus.Collector { return NewClusterUsageCollector(e) })
+	add(PoolUsageCollectorName, func(e *Exporter) prometheus.Collector { return NewPoolUsageCollector(e) })
+	add(PoolInfoCollectorName, func(e *Exporter) prometheus.Collector { return NewPoolInfoCollector(e) })
+	add(ClusterHealthCollectorName, func(e *Exporter) prometheus.Collector { return NewClusterHealthCollector(e) })
+	add(MonitorCollectorName, func(e *Exporter) prometheus.Collector { return NewMonitorCollector(e) })
+	add(MgrCollectorName, func(e *Exporter) prometheus.Collector { return NewMgrCollector(e) })
+	add(BalancerCollectorName, func(e *Exporter) prometheus.Collector { return NewBalancerCollector(e) })
+	add(OSDCollectorName, func(e *Exporter) prometheus.Collector { return NewOSDCollector(e) })
+	add(ScrubCollectorName, func(e *Exporter) prometheus.Collector { return NewScrubCollector(e) })
+	add(CrashesCollectorName, func(e *Exporter) prometheus.Collector { return NewCrashesCollector(e) })
+	add(DeviceHealthCollectorName, func(e *Exporter) prometheus.Collector { return NewDeviceHealthCollector(e) })
+	add(CephFSCollectorName, func(e *Exporter) prometheus.Collector { return NewCephFSCollector(e) })
 
 	if exporter.RbdMirror {
-		standardCollectors = append(standardCollectors, NewRbdMirrorStatusCollector(exporter))
+		add(RbdMirrorStatusCollectorName, func(e *Exporter) prometheus.Collector { return NewRbdMirrorStatusCollector(e) })
 	}
 
 	switch exporter.RgwMode {
 	case RGWModeForeground:
-		standardCollectors = append(standardCollectors, NewRGWCollector(exporter, false))
+		addRGW(false)
 	case RGWModeBackground:
-		standardCollectors = append(standardCollectors, NewRGWCollector(exporter, true))
+		addRGW(true)
 	case RGWModeDisabled:
 		// nothing to do
-	default:
-		exporter.Logger.WithField("RgwMode", exporter.RgwMode).Warn("RGW collector disabled due to invalid mode")
 	}
 
 	return standardCollectors
@@ -95,6 +467,44 @@ func (exporter *Exporter) cephVersionCmd() []byte {
 	return cmd
 }
 
+// setFSID queries the cluster's fsid, unless it is already known. It must be
+// called with refreshMu held.
+func (exporter *Exporter) setFSID() error {
+	if exporter.fsid != "" {
+		return nil
+	}
+
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "fsid",
+		"format": "json",
+	})
+	if err != nil {
+		exporter.Logger.WithError(err).Panic("failed to marshal ceph fsid command")
+	}
+
+	buf, _, err := exporter.Conn.MonCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	fsid := &struct {
+		FSID string `json:"fsid"`
+	}{}
+
+	err = json.Unmarshal(buf, fsid)
+	if err != nil {
+		return err
+	}
+
+	if fsid.FSID == "" {
+		return fmt.Errorf("empty fsid in %q", buf)
+	}
+
+	exporter.fsid = fsid.FSID
+
+	return nil
+}
+
 func CephVersionsCmd() ([]byte, error) {
 	// Ceph versions
 	cmd, err := json.Marshal(map[string]interface{}{
@@ -172,7 +582,25 @@ func (exporter *Exporter) setRbdMirror() error {
 	return nil
 }
 
+// setCephVersion refreshes the cached Ceph version once VersionTTL has passed.
+// If the refresh fails, the previously cached version keeps being used. It must
+// be called with refreshMu held.
 func (exporter *Exporter) setCephVersion() error {
+	if exporter.Version != nil && time.Since(exporter.versionAt) < exporter.VersionTTL {
+		return nil
+	}
+
+	err := exporter.queryCephVersion()
+	exporter.versionDetectFailed = err != nil
+	if err != nil && exporter.Version != nil {
+		exporter.Logger.WithError(err).Warn("failed to refresh ceph Version, using cached version")
+		return nil
+	}
+
+	return err
+}
+
+func (exporter *Exporter) queryCephVersion() error {
 	buf, _, err := exporter.Conn.MonCommand(exporter.cephVersionCmd())
 	if err != nil {
 		return err
@@ -194,6 +622,7 @@ func (exporter *Exporter) setCephVersion() error {
 	}
 
 	exporter.Version = parsedVersion
+	exporter.versionAt = time.Now()
 
 	return nil
 }
@@ -201,43 +630,382 @@ func (exporter *Exporter) setCephVersion() error {
 // Describe sends all the descriptors of the collectors included to
 // the provided channel.
 func (exporter *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	exporter.refreshMu.Lock()
+	defer exporter.refreshMu.Unlock()
+
+	exporter.refreshVersions()
+
+	for _, cc := range exporter.getCollectors(context.Background()) {
+		cc.Describe(ch)
+	}
+
+	durationDesc, successDesc, lastRefreshDesc := exporter.collectorDescs()
+	ch <- durationDesc
+	ch <- successDesc
+	ch <- lastRefreshDesc
+	ch <- exporter.scrapeQueueWaitDesc()
+	ch <- exporter.scrapeErrorsDesc()
+	ch <- exporter.clusterInfoDesc()
+	ch <- exporter.versionInfoDesc()
+	ch <- exporter.versionDetectFailedDesc()
+}
+
+// refreshVersions refreshes the Ceph version and whether rbd-mirror is
+// running. Failures are logged and leave the previous values in place, so
+// that a brief mon outage only costs the metrics that depend on the version
+// instead of the whole collection. It must be called with refreshMu held.
+func (exporter *Exporter) refreshVersions() {
 	err := exporter.setCephVersion()
 	if err != nil {
-		exporter.Logger.WithError(err).Error("failed to set ceph Version")
-		return
+		exporter.Logger.WithError(err).Error("failed to set ceph Version, collecting without version-specific metrics")
 	}
 
 	err = exporter.setRbdMirror()
 	if err != nil {
 		exporter.Logger.WithError(err).Error("failed to set rbd mirror")
-		return
 	}
+}
 
-	for _, cc := range exporter.getCollectors() {
-		cc.Describe(ch)
+// versionInfoDesc returns the descriptor of the metric carrying the Ceph
+// version of the cluster and its components as labels.
+func (exporter *Exporter) versionInfoDesc() *prometheus.Desc {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return prometheus.NewDesc(
+		fmt.Sprintf("%s_version_info", namespace),
+		"Ceph version of the cluster as reported by the monitors, always 1",
+		[]string{"version", "major", "minor", "patch"},
+		labels,
+	)
+}
+
+// versionDetectFailedDesc returns the descriptor of whether the Ceph version
+// could not be queried.
+func (exporter *Exporter) versionDetectFailedDesc() *prometheus.Desc {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return prometheus.NewDesc(
+		fmt.Sprintf("%s_exporter_version_detect_failed", namespace),
+		"Whether the last query of the Ceph version failed, leaving the collection to use the previous version if any",
+		nil,
+		labels,
+	)
+}
+
+// scrapeQueueWaitDesc returns the descriptor of the time a scrape waited for
+// the previous one to finish.
+func (exporter *Exporter) scrapeQueueWaitDesc() *prometheus.Desc {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return prometheus.NewDesc(
+		fmt.Sprintf("%s_exporter_scrape_queue_wait_seconds", namespace),
+		"Time the scrape waited for an overlapping scrape of the cluster to finish",
+		nil,
+		labels,
+	)
+}
+
+// clusterInfoDesc returns the descriptor of the metric identifying the
+// cluster by its fsid and Ceph version.
+func (exporter *Exporter) clusterInfoDesc() *prometheus.Desc {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return prometheus.NewDesc(
+		fmt.Sprintf("%s_cluster_info", namespace),
+		"Information about the cluster, always 1",
+		[]string{"fsid", "version"},
+		labels,
+	)
+}
+
+// scrapeErrorsDesc returns the descriptor of the count of errors logged by
+// each collector.
+func (exporter *Exporter) scrapeErrorsDesc() *prometheus.Desc {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return prometheus.NewDesc(
+		fmt.Sprintf("%s_scrape_errors_total", namespace),
+		"Number of errors logged by the collector since the exporter started",
+		[]string{"collector"},
+		labels,
+	)
+}
+
+// collectorDescs returns the descriptors of the metrics the exporter reports
+// about its own collectors.
+func (exporter *Exporter) collectorDescs() (duration *prometheus.Desc, success *prometheus.Desc, lastRefresh *prometheus.Desc) {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	duration = prometheus.NewDesc(
+		fmt.Sprintf("%s_exporter_collector_duration_seconds", namespace),
+		"Time taken by the collector during the last collection",
+		[]string{"collector"},
+		labels,
+	)
+	success = prometheus.NewDesc(
+		fmt.Sprintf("%s_exporter_collector_success", namespace),
+		"Whether the collector completed the last collection without errors",
+		[]string{"collector"},
+		labels,
+	)
+	lastRefresh = prometheus.NewDesc(
+		fmt.Sprintf("%s_exporter_collector_last_refresh_timestamp_seconds", namespace),
+		"Time at which the collector last finished a collection",
+		[]string{"collector"},
+		labels,
+	)
+
+	return duration, success, lastRefresh
+}
+
+// collectWithTimeout runs a single collector, forwarding its metrics to ch
+// until it either finishes or the timeout expires. A collector that runs past
+// its timeout is abandoned: anything it sends afterwards is discarded so that
+// it can't hold up the collectors that come after it. It reports whether the
+// collector finished in time.
+func (exporter *Exporter) collectWithTimeout(cc namedCollector, ch chan<- prometheus.Metric, timeout time.Duration) bool {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		cc.Collect(metrics)
+		close(metrics)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case metric, ok := <-metrics:
+			if !ok {
+				return true
+			}
+			ch <- metric
+		case <-timer.C:
+			exporter.Logger.WithFields(logrus.Fields{
+				"collector": cc.name,
+				"timeout":   timeout,
+			}).Warn("collector exceeded its collect timeout")
+
+			cc.cancel()
+
+			go func() {
+				for range metrics {
+				}
+			}()
+			return false
+		}
 	}
 }
 
 // Collect sends the collected metrics from each of the collectors to
 // prometheus. Collect could be called several times concurrently
 // and thus its run is protected by a single mutex.
+//
+// When a CacheTTL is set, the metrics from the last collection are replayed
+// until the TTL expires instead of querying the cluster on every scrape.
 func (exporter *Exporter) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
 	exporter.mu.Lock()
 	defer exporter.mu.Unlock()
 
-	err := exporter.setCephVersion()
-	if err != nil {
-		exporter.Logger.WithError(err).Error("failed to set ceph Version")
+	if len(exporter.RelabelRules) > 0 {
+		relabeled := make(chan prometheus.Metric)
+		done := make(chan struct{})
+		go func(out chan<- prometheus.Metric) {
+			relabelMetrics(exporter.RelabelRules, relabeled, out, exporter.Logger)
+			close(done)
+		}(ch)
+
+		defer func() {
+			close(relabeled)
+			<-done
+		}()
+		ch = relabeled
+	}
+
+	ch <- prometheus.MustNewConstMetric(exporter.scrapeQueueWaitDesc(), prometheus.GaugeValue, time.Since(start).Seconds())
+
+	if exporter.RefreshInterval <= 0 && exporter.CacheTTL <= 0 {
+		exporter.collectAll(ch)
 		return
 	}
 
-	err = exporter.setRbdMirror()
+	if exporter.RefreshInterval <= 0 && time.Since(exporter.cachedAt) >= exporter.CacheTTL {
+		exporter.cachedMetrics = exporter.gather()
+		exporter.cachedAt = time.Now()
+	}
+
+	for _, metric := range exporter.cachedMetrics {
+		ch <- metric
+	}
+}
+
+// backgroundRefresh collects every RefreshInterval, replacing the metrics
+// served by Collect once each collection is complete.
+func (exporter *Exporter) backgroundRefresh() {
+	ticker := time.NewTicker(exporter.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		collected := exporter.gather()
+
+		exporter.mu.Lock()
+		exporter.cachedMetrics = collected
+		exporter.cachedAt = time.Now()
+		exporter.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-exporter.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background refresh and background RGW collection of an
+// exporter that is no longer registered. Collections already running are
+// left to finish, except for the radosgw-admin commands of background RGW
+// collection, which are killed.
+func (exporter *Exporter) Close() {
+	exporter.stopOnce.Do(func() {
+		if exporter.stop != nil {
+			close(exporter.stop)
+		}
+	})
+}
+
+// gather runs collectAll, returning the collected metrics instead of sending
+// them on.
+func (exporter *Exporter) gather() []prometheus.Metric {
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	var collected []prometheus.Metric
+	go func() {
+		for metric := range metrics {
+			collected = append(collected, metric)
+		}
+		close(done)
+	}()
+
+	exporter.collectAll(metrics)
+	close(metrics)
+	<-done
+
+	return collected
+}
+
+// collectAll refreshes the cluster version information and then runs every
+// enabled collector.
+func (exporter *Exporter) collectAll(ch chan<- prometheus.Metric) {
+	exporter.refreshMu.Lock()
+	defer exporter.refreshMu.Unlock()
+
+	exporter.refreshVersions()
+
+	versionDetectFailed := 0.0
+	if exporter.versionDetectFailed {
+		versionDetectFailed = 1
+	}
+	ch <- prometheus.MustNewConstMetric(exporter.versionDetectFailedDesc(), prometheus.GaugeValue, versionDetectFailed)
+
+	if exporter.Version != nil {
+		ch <- prometheus.MustNewConstMetric(exporter.versionInfoDesc(), prometheus.GaugeValue, 1,
+			exporter.Version.String(),
+			strconv.Itoa(exporter.Version.Major),
+			strconv.Itoa(exporter.Version.Minor),
+			strconv.Itoa(exporter.Version.Patch))
+	}
+
+	err := exporter.setFSID()
 	if err != nil {
-		exporter.Logger.WithError(err).Error("failed to set rbd mirror")
-		return
+		exporter.Logger.WithError(err).Warn("failed to get ceph fsid, skipping cluster info")
+	} else if exporter.Version != nil {
+		ch <- prometheus.MustNewConstMetric(exporter.clusterInfoDesc(), prometheus.GaugeValue, 1, exporter.fsid, exporter.Version.String())
+	}
+
+	// cancelled collectors stop their commands instead of leaving them
+	// running after the collection is over
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exporter.collect(exporter.getCollectors(ctx), ch)
+}
+
+// collectorTimeout returns the time a collector may take out of the remaining
+// CollectTimeout budget, when there are total collectors in the collection and
+// after of them still to run once it is done. A collector may use whatever
+// the collectors before it left, but a quarter of the budget is kept back and
+// split between the collectors after it, so that a slow collector still leaves
+// them enough time to run.
+func (exporter *Exporter) collectorTimeout(remaining time.Duration, total int, after int) time.Duration {
+	reserved := exporter.CollectTimeout / time.Duration(4*total) * time.Duration(after)
+	if remaining <= reserved {
+		return remaining / time.Duration(after+1)
 	}
+	return remaining - reserved
+}
+
+// collect runs the given collectors in order, within CollectTimeout if one is
+// set. The duration and outcome of each collector is reported
+// alongside its metrics, as is the running count of the errors it logged.
+func (exporter *Exporter) collect(collectors []namedCollector, ch chan<- prometheus.Metric) {
+	durationDesc, successDesc, lastRefreshDesc := exporter.collectorDescs()
+	scrapeErrorsDesc := exporter.scrapeErrorsDesc()
+
+	if exporter.scrapeErrors == nil {
+		exporter.scrapeErrors = make(map[string]float64)
+	}
+
+	var deadline time.Time
+	if exporter.CollectTimeout > 0 {
+		deadline = time.Now().Add(exporter.CollectTimeout)
+	}
+
+	for i, cc := range collectors {
+		start := time.Now()
+		finished := true
+
+		if exporter.CollectTimeout <= 0 {
+			cc.Collect(ch)
+		} else {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				exporter.Logger.WithField("skipped", len(collectors)-i).Warn("collect timeout reached, skipping remaining collectors")
+				for _, skipped := range collectors[i:] {
+					ch <- prometheus.MustNewConstMetric(successDesc, prometheus.GaugeValue, 0, skipped.name)
+					ch <- prometheus.MustNewConstMetric(scrapeErrorsDesc, prometheus.CounterValue, exporter.scrapeErrors[skipped.name], skipped.name)
+				}
+				return
+			}
+
+			finished = exporter.collectWithTimeout(cc, ch, exporter.collectorTimeout(remaining, len(collectors), len(collectors)-i-1))
+		}
+
+		errors := cc.errors.Count()
+		exporter.scrapeErrors[cc.name] += float64(errors)
+
+		success := 0.0
+		if finished && errors == 0 {
+			success = 1
+		}
 
-	for _, cc := range exporter.getCollectors() {
-		cc.Collect(ch)
+		end := time.Now()
+		ch <- prometheus.MustNewConstMetric(durationDesc, prometheus.GaugeValue, end.Sub(start).Seconds(), cc.name)
+		ch <- prometheus.MustNewConstMetric(successDesc, prometheus.GaugeValue, success, cc.name)
+		ch <- prometheus.MustNewConstMetric(lastRefreshDesc, prometheus.GaugeValue, float64(end.UnixNano())/1e9, cc.name)
+		ch <- prometheus.MustNewConstMetric(scrapeErrorsDesc, prometheus.CounterValue, exporter.scrapeErrors[cc.name], cc.name)
 	}
 }
@@ -0,0 +1,554 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCollector sends a single gauge after the given delay, optionally logging
+// an error first.
+type fakeCollector struct {
+	name   string
+	desc   *prometheus.Desc
+	delay  time.Duration
+	fail   bool
+	logger *logrus.Logger
+}
+
+func newFakeCollector(name string, delay time.Duration) *fakeCollector {
+	return &fakeCollector{
+		name:  name,
+		desc:  prometheus.NewDesc(name, "fake collector", nil, nil),
+		delay: delay,
+	}
+}
+
+func (f *fakeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.desc
+}
+
+func (f *fakeCollector) Collect(ch chan<- prometheus.Metric) {
+	time.Sleep(f.delay)
+	if f.fail {
+		f.logger.Error("fake collector failed")
+	}
+	ch <- prometheus.MustNewConstMetric(f.desc, prometheus.GaugeValue, 1)
+}
+
+// selfMetricsCollector runs the given collectors through the exporter, without
+// the version lookups Exporter.Collect does first.
+type selfMetricsCollector struct {
+	exporter   *Exporter
+	collectors []namedCollector
+}
+
+func (s *selfMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, cc := range s.collectors {
+		cc.Describe(ch)
+	}
+
+	durationDesc, successDesc, lastRefreshDesc := s.exporter.collectorDescs()
+	ch <- durationDesc
+	ch <- successDesc
+	ch <- lastRefreshDesc
+	ch <- s.exporter.scrapeErrorsDesc()
+}
+
+func (s *selfMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	s.exporter.collect(s.collectors, ch)
+}
+
+func TestExporterCollectTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		timeout    time.Duration
+		collectors []*fakeCollector
+		expected   []string
+	}{
+		{
+			name:    "no timeout",
+			timeout: 0,
+			collectors: []*fakeCollector{
+				newFakeCollector("first", 0),
+				newFakeCollector("second", 10*time.Millisecond),
+			},
+			expected: []string{"first", "second"},
+		},
+		{
+			name:    "all collectors within budget",
+			timeout: time.Second,
+			collectors: []*fakeCollector{
+				newFakeCollector("first", 0),
+				newFakeCollector("second", 10*time.Millisecond),
+			},
+			expected: []string{"first", "second"},
+		},
+		{
+			name:    "slow collector does not starve the rest",
+			timeout: 300 * time.Millisecond,
+			collectors: []*fakeCollector{
+				newFakeCollector("slow", time.Minute),
+				newFakeCollector("second", 0),
+				newFakeCollector("third", 0),
+			},
+			expected: []string{"second", "third"},
+		},
+		{
+			name:    "slow collector uses the time left by the others",
+			timeout: 300 * time.Millisecond,
+			collectors: []*fakeCollector{
+				newFakeCollector("first", 0),
+				newFakeCollector("slow", 200*time.Millisecond),
+				newFakeCollector("third", 0),
+			},
+			expected: []string{"first", "slow", "third"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &Exporter{CollectTimeout: tt.timeout, Logger: logrus.New()}
+
+			collectors := make([]namedCollector, 0, len(tt.collectors))
+			for _, c := range tt.collectors {
+				collectors = append(collectors, namedCollector{Collector: c, name: c.name, errors: &errorCountHook{}, cancel: func() {}})
+			}
+
+			// each collector is followed by its duration, success, last
+			// refresh and error count metrics
+			ch := make(chan prometheus.Metric, 5*len(collectors))
+			start := time.Now()
+			exporter.collect(collectors, ch)
+			close(ch)
+
+			if tt.timeout > 0 {
+				require.Less(t, time.Since(start), tt.timeout+100*time.Millisecond)
+			}
+
+			var names []string
+			for metric := range ch {
+				for _, c := range tt.collectors {
+					if metric.Desc() == c.desc {
+						names = append(names, c.name)
+					}
+				}
+			}
+
+			require.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestExporterDisabledCollectors(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		disabled []string
+		rgwMode  int
+		expected []string
+	}{
+		{
+			name:     "all enabled",
+			expected: []string{"*ceph.ClusterUsageCollector", "*ceph.PoolUsageCollector", "*ceph.PoolInfoCollector", "*ceph.ClusterHealthCollector", "*ceph.MonitorCollector", "*ceph.MgrCollector", "*ceph.BalancerCollector", "*ceph.OSDCollector", "*ceph.ScrubCollector", "*ceph.CrashesCollector", "*ceph.DeviceHealthCollector", "*ceph.CephFSCollector"},
+		},
+		{
+			name:     "some disabled",
+			disabled: []string{"osd", "pool_info", "unknown"},
+			expected: []string{"*ceph.ClusterUsageCollector", "*ceph.PoolUsageCollector", "*ceph.ClusterHealthCollector", "*ceph.MonitorCollector", "*ceph.MgrCollector", "*ceph.BalancerCollector", "*ceph.ScrubCollector", "*ceph.CrashesCollector", "*ceph.DeviceHealthCollector", "*ceph.CephFSCollector"},
+		},
+		{
+			name:     "rgw disabled by name",
+			disabled: []string{"cluster_usage", "pool_usage", "pool_info", "health", "monitors", "mgr", "balancer", "osd", "scrub", "crashes", "device_health", "cephfs", "rgw"},
+			rgwMode:  RGWModeForeground,
+			expected: []string{},
+		},
+		{
+			name:     "invalid rgw mode",
+			disabled: []string{"cluster_usage", "pool_usage", "pool_info", "health", "monitors", "mgr", "balancer", "osd", "scrub", "crashes", "device_health", "cephfs"},
+			rgwMode:  3,
+			expected: []string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := NewExporter(nil, "ceph", ExporterOptions{RgwMode: tt.rgwMode, DisabledCollectors: tt.disabled}, logrus.New())
+			exporter.Version = Pacific
+			require.True(t, isRGWMode(exporter.RgwMode))
+
+			names := []string{}
+			for _, cc := range exporter.getCollectors(context.Background()) {
+				names = append(names, fmt.Sprintf("%T", cc.Collector))
+			}
+
+			require.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestExporterRGWBackgroundCollectorsReused(t *testing.T) {
+	instances := []RGWInstance{
+		{Zone: "us-east", User: "rgw-east"},
+		{Zone: "us-west", User: "rgw-west"},
+	}
+	disabled := []string{"cluster_usage", "pool_usage", "pool_info", "health", "monitors", "mgr", "balancer", "osd", "scrub", "crashes", "device_health", "cephfs"}
+
+	exporter := NewExporter(nil, "ceph", ExporterOptions{
+		RgwMode:            RGWModeBackground,
+		RadosgwAdminPath:   "/nonexistent/radosgw-admin",
+		RGWInstances:       instances,
+		DisabledCollectors: disabled,
+	}, logrus.New())
+	defer exporter.Close()
+
+	require.Len(t, exporter.rgwBackground, len(instances))
+
+	// every collection and description is served by the same collectors
+	for i := 0; i < 2; i++ {
+		collectors := exporter.getCollectors(context.Background())
+		require.Len(t, collectors, len(instances))
+
+		for j, cc := range collectors {
+			require.Equal(t, "rgw/"+instances[j].Zone, cc.name)
+			require.Same(t, exporter.rgwBackground[j], cc.Collector)
+		}
+	}
+}
+
+func TestExporterRGWInstances(t *testing.T) {
+	instances := []RGWInstance{
+		{Zone: "us-east", User: "rgw-east", Config: "/etc/ceph/east.conf"},
+		{Zone: "us-west", User: "rgw-west", Config: "/etc/ceph/west.conf"},
+	}
+	disabled := []string{"cluster_usage", "pool_usage", "pool_info", "health", "monitors", "mgr", "balancer", "osd", "scrub", "crashes", "device_health", "cephfs"}
+
+	exporter := NewExporter(nil, "ceph", ExporterOptions{
+		Config:             "/etc/ceph/ceph.conf",
+		User:               "admin",
+		RgwMode:            RGWModeForeground,
+		RGWInstances:       instances,
+		DisabledCollectors: disabled,
+	}, logrus.New())
+
+	collectors := exporter.getCollectors(context.Background())
+	require.Len(t, collectors, len(instances))
+
+	for i, cc := range collectors {
+		require.Equal(t, "rgw/"+instances[i].Zone, cc.name)
+
+		rgw := cc.Collector.(*RGWCollector)
+		require.Equal(t, instances[i].User, rgw.user)
+		require.Equal(t, instances[i].Config, rgw.config)
+	}
+}
+
+func TestExporterNamespace(t *testing.T) {
+	exporter := &Exporter{Cluster: "ceph", Namespace: "ceph_do", Logger: logrus.New()}
+	require.Contains(t, exporter.scrapeQueueWaitDesc().String(), `"ceph_do_exporter_scrape_queue_wait_seconds"`)
+
+	// the namespace is passed on to the collectors
+	cc := exporter.newNamedCollector(context.Background(), CrashesCollectorName, func(e *Exporter) prometheus.Collector {
+		return NewCrashesCollector(e)
+	})
+	require.Contains(t, cc.Collector.(*CrashesCollector).crashReportsDesc.String(), `"ceph_do_crash_reports"`)
+
+	// and defaults to ceph
+	exporter.Namespace = ""
+	require.Contains(t, exporter.scrapeQueueWaitDesc().String(), `"ceph_exporter_scrape_queue_wait_seconds"`)
+}
+
+func TestExporterCollectorSelfMetrics(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		timeout time.Duration
+		delay   time.Duration
+		fail    bool
+		reMatch []*regexp.Regexp
+	}{
+		{
+			name: "collector succeeds",
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_collector_duration_seconds{cluster="ceph",collector="fake"} `),
+				regexp.MustCompile(`ceph_exporter_collector_success{cluster="ceph",collector="fake"} 1`),
+				regexp.MustCompile(`ceph_exporter_collector_last_refresh_timestamp_seconds{cluster="ceph",collector="fake"} 1\.[0-9]+e\+09`),
+				regexp.MustCompile(`ceph_scrape_errors_total{cluster="ceph",collector="fake"} 0`),
+			},
+		},
+		{
+			name: "collector logs an error",
+			fail: true,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_collector_duration_seconds{cluster="ceph",collector="fake"} `),
+				regexp.MustCompile(`ceph_exporter_collector_success{cluster="ceph",collector="fake"} 0`),
+				regexp.MustCompile(`ceph_scrape_errors_total{cluster="ceph",collector="fake"} 1`),
+			},
+		},
+		{
+			name:    "collector times out",
+			timeout: 50 * time.Millisecond,
+			delay:   time.Minute,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_exporter_collector_duration_seconds{cluster="ceph",collector="fake"} 0.0[5-9]`),
+				regexp.MustCompile(`ceph_exporter_collector_success{cluster="ceph",collector="fake"} 0`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &Exporter{Cluster: "ceph", CollectTimeout: tt.timeout, Logger: logrus.New()}
+
+			cc := exporter.newNamedCollector(context.Background(), "fake", func(e *Exporter) prometheus.Collector {
+				c := newFakeCollector("fake_metric", tt.delay)
+				c.fail = tt.fail
+				c.logger = e.Logger
+				return c
+			})
+
+			collector := &selfMetricsCollector{exporter: exporter, collectors: []namedCollector{cc}}
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), string(buf))
+			}
+		})
+	}
+}
+
+func TestExporterScrapeErrorsAccumulate(t *testing.T) {
+	exporter := &Exporter{Cluster: "ceph", Logger: logrus.New()}
+
+	// the collectors are created anew for every collection, as they are by
+	// collectAll
+	var count float64
+	for i := 0; i < 2; i++ {
+		cc := exporter.newNamedCollector(context.Background(), "fake", func(e *Exporter) prometheus.Collector {
+			c := newFakeCollector("fake_metric", 0)
+			c.fail = true
+			c.logger = e.Logger
+			return c
+		})
+
+		ch := make(chan prometheus.Metric, 5)
+		exporter.collect([]namedCollector{cc}, ch)
+		close(ch)
+
+		for metric := range ch {
+			if metric.Desc().String() != exporter.scrapeErrorsDesc().String() {
+				continue
+			}
+
+			out := &dto.Metric{}
+			require.NoError(t, metric.Write(out))
+			count = out.GetCounter().GetValue()
+		}
+	}
+
+	require.Equal(t, 2.0, count)
+}
+
+func TestExporterScrapeQueueWait(t *testing.T) {
+	// a fresh, empty cache keeps Collect from querying the cluster
+	exporter := &Exporter{Cluster: "ceph", CacheTTL: time.Hour, cachedAt: time.Now(), Logger: logrus.New()}
+
+	exporter.mu.Lock()
+	ch := make(chan prometheus.Metric, 1)
+	go exporter.Collect(ch)
+
+	time.Sleep(100 * time.Millisecond)
+	exporter.mu.Unlock()
+
+	metric := <-ch
+	require.Equal(t, exporter.scrapeQueueWaitDesc().String(), metric.Desc().String())
+
+	out := &dto.Metric{}
+	require.NoError(t, metric.Write(out))
+	require.GreaterOrEqual(t, out.GetGauge().GetValue(), 0.1)
+}
+
+func TestExporterBackgroundRefresh(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "version"
+	})).Return([]byte(`{"version": "ceph version 16.2.7 (dd0603118f56ab514f133c8d2e3adfc983942503) pacific (stable)"}`), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "versions"
+	})).Return([]byte(`{"mon": {"ceph version 16.2.7 (dd0603118f56ab514f133c8d2e3adfc983942503) pacific (stable)": 3}}`), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "fsid"
+	})).Return([]byte(`{"fsid": "f0b1e2a4-3c5d-4e6f-8a9b-0c1d2e3f4a5b"}`), "", nil)
+
+	exporter := NewExporter(conn, "ceph", ExporterOptions{RefreshInterval: 10 * time.Millisecond, DisabledCollectors: CollectorNames}, logrus.New())
+
+	require.Eventually(t, func() bool {
+		exporter.mu.Lock()
+		defer exporter.mu.Unlock()
+		return !exporter.cachedAt.IsZero()
+	}, time.Second, 10*time.Millisecond)
+
+	// no refresh happens once the exporter is closed
+	exporter.Close()
+	time.Sleep(30 * time.Millisecond)
+
+	exporter.mu.Lock()
+	closedAt := exporter.cachedAt
+	exporter.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	require.Equal(t, closedAt, exporter.cachedAt)
+}
+
+func TestExporterCollectServesBackgroundRefresh(t *testing.T) {
+	metric := newFakeCollector("fake", 0)
+
+	// without a Conn, Collect can only succeed by replaying the last refresh
+	exporter := &Exporter{Cluster: "ceph", RefreshInterval: time.Minute, Logger: logrus.New()}
+	exporter.cachedMetrics = []prometheus.Metric{prometheus.MustNewConstMetric(metric.desc, prometheus.GaugeValue, 1)}
+
+	ch := make(chan prometheus.Metric, 2)
+	exporter.Collect(ch)
+	close(ch)
+
+	var descs []string
+	for m := range ch {
+		descs = append(descs, m.Desc().String())
+	}
+
+	require.Equal(t, []string{exporter.scrapeQueueWaitDesc().String(), metric.desc.String()}, descs)
+}
+
+func TestExporterVersionCache(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.Anything).Return(
+		[]byte(`{"version": "ceph version 16.2.7 (dd0603118f56ab514f133c8d2e3adfc983942503) pacific (stable)"}`), "", nil,
+	).Once()
+	conn.On("MonCommand", mock.Anything).Return(nil, "", errors.New("fake error"))
+
+	exporter := &Exporter{Conn: conn, Cluster: "ceph", VersionTTL: time.Hour, Logger: logrus.New()}
+
+	// the version is queried once and then cached
+	require.NoError(t, exporter.setCephVersion())
+	require.NoError(t, exporter.setCephVersion())
+	require.Equal(t, 7, exporter.Version.Patch)
+	conn.AssertNumberOfCalls(t, "MonCommand", 1)
+
+	// a failed refresh falls back to the cached version
+	exporter.versionAt = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, exporter.setCephVersion())
+	require.Equal(t, 7, exporter.Version.Patch)
+	conn.AssertNumberOfCalls(t, "MonCommand", 2)
+
+	// without a cached version the failure is returned
+	exporter.Version = nil
+	require.Error(t, exporter.setCephVersion())
+}
+
+func TestExporterClusterInfo(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "version"
+	})).Return([]byte(`{"version": "ceph version 16.2.7 (dd0603118f56ab514f133c8d2e3adfc983942503) pacific (stable)"}`), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "versions"
+	})).Return([]byte(`{"mon": {"ceph version 16.2.7 (dd0603118f56ab514f133c8d2e3adfc983942503) pacific (stable)": 3}}`), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "fsid"
+	})).Return([]byte(`{"fsid": "f0b1e2a4-3c5d-4e6f-8a9b-0c1d2e3f4a5b"}`), "", nil).Once()
+
+	exporter := NewExporter(conn, "ceph", ExporterOptions{VersionTTL: time.Hour, DisabledCollectors: CollectorNames}, logrus.New())
+
+	expected := `
+# HELP ceph_cluster_info Information about the cluster, always 1
+# TYPE ceph_cluster_info gauge
+ceph_cluster_info{cluster="ceph",fsid="f0b1e2a4-3c5d-4e6f-8a9b-0c1d2e3f4a5b",version="16.2.7"} 1
+# HELP ceph_version_info Ceph version of the cluster as reported by the monitors, always 1
+# TYPE ceph_version_info gauge
+ceph_version_info{cluster="ceph",major="16",minor="2",patch="7",version="16.2.7"} 1
+`
+
+	// the fsid is only queried once
+	for i := 0; i < 2; i++ {
+		require.NoError(t, testutil.CollectAndCompare(exporter, strings.NewReader(expected), "ceph_cluster_info", "ceph_version_info"))
+	}
+}
+
+func TestExporterCollectWithoutVersion(t *testing.T) {
+	var disabled []string
+	for _, name := range CollectorNames {
+		if name != ClusterHealthCollectorName {
+			disabled = append(disabled, name)
+		}
+	}
+
+	// the health fixtures have no answer to the version queries
+	exporter := NewExporter(newFakeConn(t, "health"), "ceph", ExporterOptions{DisabledCollectors: disabled}, logrus.New())
+
+	expected := `
+# HELP ceph_exporter_version_detect_failed Whether the last query of the Ceph version failed, leaving the collection to use the previous version if any
+# TYPE ceph_exporter_version_detect_failed gauge
+ceph_exporter_version_detect_failed{cluster="ceph"} 1
+# HELP ceph_osds_down Count of OSDs that are in DOWN state
+# TYPE ceph_osds_down gauge
+ceph_osds_down{cluster="ceph"} 1
+`
+
+	err := testutil.CollectAndCompare(exporter, strings.NewReader(expected), "ceph_exporter_version_detect_failed", "ceph_osds_down", "ceph_cluster_info", "ceph_version_info")
+	require.NoError(t, err)
+}
+
+func TestExporterCollectTimeoutCancelsCollector(t *testing.T) {
+	exporter := &Exporter{Cluster: "ceph", CollectTimeout: 50 * time.Millisecond, Logger: logrus.New()}
+
+	var ctx context.Context
+	cc := exporter.newNamedCollector(context.Background(), "fake", func(e *Exporter) prometheus.Collector {
+		ctx = e.collectContext()
+		return newFakeCollector("fake", time.Second)
+	})
+
+	ch := make(chan prometheus.Metric, 4)
+	exporter.collect([]namedCollector{cc}, ch)
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("context of the timed out collector was not cancelled")
+	}
+}
@@ -0,0 +1,123 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeConn is a Conn answering commands with the canned output found in a
+// directory under testdata. The output of a command is read from a file named
+// after the command as it would be typed on the CLI, with underscores for
+// spaces: the prefix followed by the values of its other arguments, ordered by
+// argument name, and an extension for its format. For instance
+// {"prefix": "osd tree", "states": ["down"], "format": "json"} is answered
+// with osd_tree_down.json, and {"prefix": "status", "format": "plain"} with
+// status.txt. Commands without a fixture fail, as do pools without stats.
+type fakeConn struct {
+	t         *testing.T
+	dir       string
+	poolStats map[string]*PoolStat
+}
+
+// *fakeConn must implement the Conn.
+var _ Conn = &fakeConn{}
+
+// newFakeConn returns a fakeConn answering with the fixtures in
+// testdata/<dir>.
+func newFakeConn(t *testing.T, dir string) *fakeConn {
+	return &fakeConn{
+		t:         t,
+		dir:       filepath.Join("testdata", dir),
+		poolStats: make(map[string]*PoolStat),
+	}
+}
+
+// fixture returns the name of the file holding the output of the command.
+func (c *fakeConn) fixture(args []byte) (string, error) {
+	var cmd map[string]interface{}
+	if err := json.Unmarshal(args, &cmd); err != nil {
+		return "", err
+	}
+
+	prefix, _ := cmd["prefix"].(string)
+	words := strings.Fields(prefix)
+
+	names := make([]string, 0, len(cmd))
+	for name := range cmd {
+		if name != "prefix" && name != "format" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch v := cmd[name].(type) {
+		case []interface{}:
+			for _, value := range v {
+				words = append(words, fmt.Sprint(value))
+			}
+		default:
+			words = append(words, fmt.Sprint(v))
+		}
+	}
+
+	ext := ".json"
+	if f, _ := cmd["format"].(string); f != "" && f != string(jsonFormat) {
+		ext = ".txt"
+	}
+
+	return strings.Join(words, "_") + ext, nil
+}
+
+func (c *fakeConn) command(args []byte) ([]byte, string, error) {
+	name, err := c.fixture(args)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(c.dir, name))
+	if os.IsNotExist(err) {
+		c.t.Logf("no fixture %s for command %s", name, args)
+		return nil, "", fmt.Errorf("no fixture %s", name)
+	}
+	return buf, "", err
+}
+
+func (c *fakeConn) MonCommand(args []byte) ([]byte, string, error) {
+	return c.command(args)
+}
+
+func (c *fakeConn) MgrCommand(args [][]byte) ([]byte, string, error) {
+	if len(args) != 1 {
+		return nil, "", fmt.Errorf("expected a single mgr command, got %d", len(args))
+	}
+	return c.command(args[0])
+}
+
+func (c *fakeConn) GetPoolStats(pool string) (*PoolStat, error) {
+	stat, ok := c.poolStats[pool]
+	if !ok {
+		return nil, fmt.Errorf("no stats for pool %s", pool)
+	}
+	return stat, nil
+}
@@ -0,0 +1,189 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCollectorFixtures runs collectors against the output of a small Pacific
+// cluster, one OSD of which is down, recorded under testdata. Only the listed
+// metrics are compared.
+func TestCollectorFixtures(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		newCollector func(*Exporter) prometheus.Collector
+		metrics      []string
+		expected     string
+	}{
+		{
+			name:         "osd",
+			newCollector: func(e *Exporter) prometheus.Collector { return NewOSDCollector(e) },
+			metrics: []string{
+				"ceph_osd_up",
+				"ceph_osd_in",
+				"ceph_osd_utilization",
+				"ceph_osd_perf_commit_latency_seconds",
+				"ceph_osd_full_ratio",
+				"ceph_osd_pg_upmap_items_total",
+				"ceph_pool_undersized_pgs",
+			},
+			expected: `
+# HELP ceph_osd_up OSD Up Status
+# TYPE ceph_osd_up gauge
+ceph_osd_up{cluster="ceph",device_class="hdd",host="ceph-01",osd="osd.0",rack="",root="default"} 1
+ceph_osd_up{cluster="ceph",device_class="ssd",host="ceph-01",osd="osd.1",rack="",root="default"} 0
+# HELP ceph_osd_in OSD In Status
+# TYPE ceph_osd_in gauge
+ceph_osd_in{cluster="ceph",device_class="hdd",host="ceph-01",osd="osd.0",rack="",root="default"} 1
+ceph_osd_in{cluster="ceph",device_class="ssd",host="ceph-01",osd="osd.1",rack="",root="default"} 0
+# HELP ceph_osd_utilization OSD Utilization
+# TYPE ceph_osd_utilization gauge
+ceph_osd_utilization{cluster="ceph",device_class="hdd",host="ceph-01",osd="osd.0",rack="",root="default"} 10
+ceph_osd_utilization{cluster="ceph",device_class="ssd",host="ceph-01",osd="osd.1",rack="",root="default"} 0
+# HELP ceph_osd_perf_commit_latency_seconds OSD Perf Commit Latency
+# TYPE ceph_osd_perf_commit_latency_seconds gauge
+ceph_osd_perf_commit_latency_seconds{cluster="ceph",device_class="hdd",host="ceph-01",osd="osd.0",rack="",root="default"} 0.003
+ceph_osd_perf_commit_latency_seconds{cluster="ceph",device_class="ssd",host="ceph-01",osd="osd.1",rack="",root="default"} 0
+# HELP ceph_osd_full_ratio OSD Full Ratio Value
+# TYPE ceph_osd_full_ratio gauge
+ceph_osd_full_ratio{cluster="ceph"} 0.95
+# HELP ceph_osd_pg_upmap_items_total OSD PG-Upmap Exception Table Entry Count
+# TYPE ceph_osd_pg_upmap_items_total gauge
+ceph_osd_pg_upmap_items_total{cluster="ceph"} 1
+# HELP ceph_pool_undersized_pgs Number of PGs of the pool with fewer copies or shards than configured
+# TYPE ceph_pool_undersized_pgs gauge
+ceph_pool_undersized_pgs{cluster="ceph",pool="cephfs_data"} 1
+ceph_pool_undersized_pgs{cluster="ceph",pool="rbd"} 0
+`,
+		},
+		{
+			name:         "pool",
+			newCollector: func(e *Exporter) prometheus.Collector { return NewPoolInfoCollector(e) },
+			metrics: []string{
+				"ceph_pool_size",
+				"ceph_pool_pg_num",
+				"ceph_pool_expansion_factor",
+				"ceph_pool_quota_max_bytes",
+				"ceph_pool_quota_enabled",
+				"ceph_pool_autoscale_pg_num",
+				"ceph_pool_autoscale_pg_num_ideal",
+				"ceph_pool_autoscale_would_adjust",
+				"ceph_pool_autoscale_bias",
+			},
+			expected: `
+# HELP ceph_pool_size Total copies or chunks of an object that need to be present for a healthy cluster
+# TYPE ceph_pool_size gauge
+ceph_pool_size{cluster="ceph",pool="ec_data",profile="ec-4-2",root="hdd-root"} 6
+ceph_pool_size{cluster="ceph",pool="rbd",profile="replicated",root="default"} 3
+# HELP ceph_pool_pg_num The total count of PGs alotted to a pool
+# TYPE ceph_pool_pg_num gauge
+ceph_pool_pg_num{cluster="ceph",pool="ec_data",profile="ec-4-2",root="hdd-root"} 64
+ceph_pool_pg_num{cluster="ceph",pool="rbd",profile="replicated",root="default"} 32
+# HELP ceph_pool_expansion_factor Data expansion multiplier for a pool
+# TYPE ceph_pool_expansion_factor gauge
+ceph_pool_expansion_factor{cluster="ceph",pool="ec_data",profile="ec-4-2",root="hdd-root"} 1.5
+ceph_pool_expansion_factor{cluster="ceph",pool="rbd",profile="replicated",root="default"} 3
+# HELP ceph_pool_quota_max_bytes Maximum amount of bytes of data allowed in a pool
+# TYPE ceph_pool_quota_max_bytes gauge
+ceph_pool_quota_max_bytes{cluster="ceph",pool="ec_data",profile="ec-4-2",root="hdd-root"} 1.099511627776e+12
+ceph_pool_quota_max_bytes{cluster="ceph",pool="rbd",profile="replicated",root="default"} 0
+# HELP ceph_pool_quota_enabled Whether a byte or object quota is set on the pool (1) or not (0)
+# TYPE ceph_pool_quota_enabled gauge
+ceph_pool_quota_enabled{cluster="ceph",pool="ec_data",profile="ec-4-2",root="hdd-root"} 1
+ceph_pool_quota_enabled{cluster="ceph",pool="rbd",profile="replicated",root="default"} 0
+# HELP ceph_pool_autoscale_pg_num PG count of the pool as seen by the PG autoscaler
+# TYPE ceph_pool_autoscale_pg_num gauge
+ceph_pool_autoscale_pg_num{cluster="ceph",pool="ec_data"} 64
+ceph_pool_autoscale_pg_num{cluster="ceph",pool="rbd"} 32
+# HELP ceph_pool_autoscale_pg_num_ideal PG count the PG autoscaler would set for the pool
+# TYPE ceph_pool_autoscale_pg_num_ideal gauge
+ceph_pool_autoscale_pg_num_ideal{cluster="ceph",pool="ec_data"} 256
+ceph_pool_autoscale_pg_num_ideal{cluster="ceph",pool="rbd"} 32
+# HELP ceph_pool_autoscale_would_adjust Whether the PG autoscaler would change the PG count of the pool (1) or not (0)
+# TYPE ceph_pool_autoscale_would_adjust gauge
+ceph_pool_autoscale_would_adjust{cluster="ceph",pool="ec_data"} 1
+ceph_pool_autoscale_would_adjust{cluster="ceph",pool="rbd"} 0
+# HELP ceph_pool_autoscale_bias Multiplier applied by the PG autoscaler to the ideal PG count of the pool
+# TYPE ceph_pool_autoscale_bias gauge
+ceph_pool_autoscale_bias{cluster="ceph",pool="ec_data"} 4
+ceph_pool_autoscale_bias{cluster="ceph",pool="rbd"} 1
+`,
+		},
+		{
+			name:         "health",
+			newCollector: func(e *Exporter) prometheus.Collector { return NewClusterHealthCollector(e) },
+			metrics: []string{
+				"ceph_health_status",
+				"ceph_health_check",
+				"ceph_health_check_count",
+				"ceph_osds_down",
+				"ceph_degraded_objects",
+				"ceph_pgs_by_state",
+				"ceph_client_io_read_bytes",
+				"ceph_client_io_write_ops",
+			},
+			expected: `
+# HELP ceph_health_status Health status of Cluster, can vary only between 3 states (err:2, warn:1, ok:0)
+# TYPE ceph_health_status gauge
+ceph_health_status{cluster="ceph"} 1
+# HELP ceph_health_check Severity of a raised health check (warn:1, err:2)
+# TYPE ceph_health_check gauge
+ceph_health_check{check="OSD_DOWN",cluster="ceph"} 1
+ceph_health_check{check="PG_DEGRADED",cluster="ceph"} 1
+# HELP ceph_health_check_count No. of items a raised health check is about
+# TYPE ceph_health_check_count gauge
+ceph_health_check_count{check="OSD_DOWN",cluster="ceph"} 1
+ceph_health_check_count{check="PG_DEGRADED",cluster="ceph"} 1
+# HELP ceph_osds_down Count of OSDs that are in DOWN state
+# TYPE ceph_osds_down gauge
+ceph_osds_down{cluster="ceph"} 1
+# HELP ceph_degraded_objects No. of degraded objects across all PGs, includes replicas
+# TYPE ceph_degraded_objects gauge
+ceph_degraded_objects{cluster="ceph"} 12
+# HELP ceph_pgs_by_state No. of PGs in the cluster with exactly the combination of states
+# TYPE ceph_pgs_by_state gauge
+ceph_pgs_by_state{cluster="ceph",state="active+clean"} 1
+ceph_pgs_by_state{cluster="ceph",state="active+clean+scrubbing+deep"} 1
+ceph_pgs_by_state{cluster="ceph",state="active+undersized+degraded"} 1
+# HELP ceph_client_io_read_bytes Rate of bytes being read by all clients per second
+# TYPE ceph_client_io_read_bytes gauge
+ceph_client_io_read_bytes{cluster="ceph"} 4096
+# HELP ceph_client_io_write_ops Total client write I/O ops on the cluster measured per second
+# TYPE ceph_client_io_write_ops gauge
+ceph_client_io_write_ops{cluster="ceph"} 4
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := tt.newCollector(&Exporter{
+				Conn:       newFakeConn(t, tt.name),
+				Cluster:    "ceph",
+				Logger:     logrus.New(),
+				Version:    Pacific,
+				knownPools: newPoolSet(),
+			})
+
+			err := testutil.CollectAndCompare(collector, strings.NewReader(tt.expected), tt.metrics...)
+			require.NoError(t, err)
+		})
+	}
+}
@@ -62,6 +62,14 @@ type ClusterHealthCollector struct {
 	// based on criticality.
 	HealthStatusInterpreter prometheus.Gauge
 
+	// HealthCheck shows the severity of each health check currently raised,
+	// 1 for HEALTH_WARN and 2 for HEALTH_ERR.
+	HealthCheck *prometheus.Desc
+
+	// HealthCheckCount shows the number of items a raised health check is
+	// about, such as the OSDs down for OSD_DOWN, when Ceph reports it.
+	HealthCheckCount *prometheus.Desc
+
 	// MONsDown show the no. of Monitor that are int DOWN state
 	MONsDown *prometheus.Desc
 
@@ -71,6 +79,10 @@ type ClusterHealthCollector struct {
 	// PGstate contains state of all PGs labelled with the name of states.
 	PGState *prometheus.Desc
 
+	// PGsByState shows the no. of PGs in each combination of states, such
+	// as active+clean+scrubbing, as listed by the pgmap.
+	PGsByState *prometheus.Desc
+
 	// ActivePGs shows the no. of PGs the cluster is actively serving data
 	// from.
 	ActivePGs *prometheus.Desc
@@ -135,7 +147,9 @@ type ClusterHealthCollector struct {
 	BackfillingPGs *prometheus.Desc
 
 	// BackfillWaitPGs depicts no. of PGs that are in backfill_wait state.
-	// The PGs in this state are still in queue to start backfill on them.
+	// The PGs in this state are still in queue to start backfill on them. A
+	// large count with few BackfillingPGs usually means recovery is being
+	// throttled by osd_max_backfills.
 	BackfillWaitPGs *prometheus.Desc
 
 	// ForcedRecoveryPGs depicts no. of PGs that are undergoing forced recovery.
@@ -165,6 +179,14 @@ type ClusterHealthCollector struct {
 	// SlowOps depicts no. of total slow ops in the cluster
 	SlowOps *prometheus.Desc
 
+	// OSDSlowOps flags the OSDs named by the SLOW_OPS health check. Ceph only
+	// names up to 10 daemons, and doesn't report slow ops counts per daemon.
+	OSDSlowOps *prometheus.Desc
+
+	// SlowOpsDaemons depicts no. of daemons of each type named by the
+	// SLOW_OPS health check.
+	SlowOpsDaemons *prometheus.Desc
+
 	// DegradedObjectsCount gives the no. of RADOS objects are constitute the degraded PGs.
 	// This includes object replicas in its count.
 	DegradedObjectsCount *prometheus.Desc
@@ -179,6 +201,14 @@ type ClusterHealthCollector struct {
 	// MisplacedRatio shows the ratio of misplaced objects to total objects
 	MisplacedRatio *prometheus.Desc
 
+	// DegradedRatio shows the ratio of degraded objects to total objects,
+	// both including replicas.
+	DegradedRatio *prometheus.Desc
+
+	// UnfoundObjectsCount gives the no. of RADOS objects the OSDs know to
+	// exist but whose data couldn't be found on any of them.
+	UnfoundObjectsCount *prometheus.Desc
+
 	// NewCrashReportCount reports if new Ceph daemon crash reports are available
 	NewCrashReportCount *prometheus.Desc
 
@@ -285,6 +315,7 @@ const (
 func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 
 	collector := &ClusterHealthCollector{
 		conn:    exporter.Conn,
@@ -367,56 +398,64 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 			"TOO_FEW_PGS":                          1,
 			"TOO_MANY_PGS":                         1},
 
-		HealthStatus: prometheus.NewDesc(fmt.Sprintf("%s_health_status", cephNamespace), "Health status of Cluster, can vary only between 3 states (err:2, warn:1, ok:0)", nil, labels),
-		//HealthStatusInterpreter: prometheus.NewDesc(fmt.Sprintf("%s_health_status_interp", cephNamespace), "Health status of Cluster, can vary only between 4 states (err:3, critical_warn:2, soft_warn:1, ok:0)", nil, labels),
+		HealthStatus: prometheus.NewDesc(fmt.Sprintf("%s_health_status", namespace), "Health status of Cluster, can vary only between 3 states (err:2, warn:1, ok:0)", nil, labels),
+		//HealthStatusInterpreter: prometheus.NewDesc(fmt.Sprintf("%s_health_status_interp", namespace), "Health status of Cluster, can vary only between 4 states (err:3, critical_warn:2, soft_warn:1, ok:0)", nil, labels),
 		HealthStatusInterpreter: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "health_status_interp",
 				Help:        "Health status of Cluster, can vary only between 4 states (err:3, critical_warn:2, soft_warn:1, ok:0)",
 				ConstLabels: labels,
 			},
 		),
-		MONsDown:          prometheus.NewDesc(fmt.Sprintf("%s_mons_down", cephNamespace), "Count of Mons that are in DOWN state", nil, labels),
-		TotalPGs:          prometheus.NewDesc(fmt.Sprintf("%s_total_pgs", cephNamespace), "Total no. of PGs in the cluster", nil, labels),
-		PGState:           prometheus.NewDesc(fmt.Sprintf("%s_pg_state", cephNamespace), "State of PGs in the cluster", []string{"state"}, labels),
-		ActivePGs:         prometheus.NewDesc(fmt.Sprintf("%s_active_pgs", cephNamespace), "No. of active PGs in the cluster", nil, labels),
-		ScrubbingPGs:      prometheus.NewDesc(fmt.Sprintf("%s_scrubbing_pgs", cephNamespace), "No. of scrubbing PGs in the cluster", nil, labels),
-		DeepScrubbingPGs:  prometheus.NewDesc(fmt.Sprintf("%s_deep_scrubbing_pgs", cephNamespace), "No. of deep scrubbing PGs in the cluster", nil, labels),
-		RecoveringPGs:     prometheus.NewDesc(fmt.Sprintf("%s_recovering_pgs", cephNamespace), "No. of recovering PGs in the cluster", nil, labels),
-		RecoveryWaitPGs:   prometheus.NewDesc(fmt.Sprintf("%s_recovery_wait_pgs", cephNamespace), "No. of PGs in the cluster with recovery_wait state", nil, labels),
-		BackfillingPGs:    prometheus.NewDesc(fmt.Sprintf("%s_backfilling_pgs", cephNamespace), "No. of backfilling PGs in the cluster", nil, labels),
-		BackfillWaitPGs:   prometheus.NewDesc(fmt.Sprintf("%s_backfill_wait_pgs", cephNamespace), "No. of PGs in the cluster with backfill_wait state", nil, labels),
-		ForcedRecoveryPGs: prometheus.NewDesc(fmt.Sprintf("%s_forced_recovery_pgs", cephNamespace), "No. of PGs in the cluster with forced_recovery state", nil, labels),
-		ForcedBackfillPGs: prometheus.NewDesc(fmt.Sprintf("%s_forced_backfill_pgs", cephNamespace), "No. of PGs in the cluster with forced_backfill state", nil, labels),
-		DownPGs:           prometheus.NewDesc(fmt.Sprintf("%s_down_pgs", cephNamespace), "No. of PGs in the cluster in down state", nil, labels),
-		IncompletePGs:     prometheus.NewDesc(fmt.Sprintf("%s_incomplete_pgs", cephNamespace), "No. of PGs in the cluster in incomplete state", nil, labels),
-		InconsistentPGs:   prometheus.NewDesc(fmt.Sprintf("%s_inconsistent_pgs", cephNamespace), "No. of PGs in the cluster in inconsistent state", nil, labels),
-		SnaptrimPGs:       prometheus.NewDesc(fmt.Sprintf("%s_snaptrim_pgs", cephNamespace), "No. of snaptrim PGs in the cluster", nil, labels),
-		SnaptrimWaitPGs:   prometheus.NewDesc(fmt.Sprintf("%s_snaptrim_wait_pgs", cephNamespace), "No. of PGs in the cluster with snaptrim_wait state", nil, labels),
-		RepairingPGs:      prometheus.NewDesc(fmt.Sprintf("%s_repairing_pgs", cephNamespace), "No. of PGs in the cluster with repair state", nil, labels),
+		HealthCheck:       prometheus.NewDesc(fmt.Sprintf("%s_health_check", namespace), "Severity of a raised health check (warn:1, err:2)", []string{"check"}, labels),
+		HealthCheckCount:  prometheus.NewDesc(fmt.Sprintf("%s_health_check_count", namespace), "No. of items a raised health check is about", []string{"check"}, labels),
+		MONsDown:          prometheus.NewDesc(fmt.Sprintf("%s_mons_down", namespace), "Count of Mons that are in DOWN state", nil, labels),
+		TotalPGs:          prometheus.NewDesc(fmt.Sprintf("%s_total_pgs", namespace), "Total no. of PGs in the cluster", nil, labels),
+		PGState:           prometheus.NewDesc(fmt.Sprintf("%s_pg_state", namespace), "State of PGs in the cluster", []string{"state"}, labels),
+		PGsByState:        prometheus.NewDesc(fmt.Sprintf("%s_pgs_by_state", namespace), "No. of PGs in the cluster with exactly the combination of states", []string{"state"}, labels),
+		ActivePGs:         prometheus.NewDesc(fmt.Sprintf("%s_active_pgs", namespace), "No. of active PGs in the cluster", nil, labels),
+		ScrubbingPGs:      prometheus.NewDesc(fmt.Sprintf("%s_scrubbing_pgs", namespace), "No. of scrubbing PGs in the cluster", nil, labels),
+		DeepScrubbingPGs:  prometheus.NewDesc(fmt.Sprintf("%s_deep_scrubbing_pgs", namespace), "No. of deep scrubbing PGs in the cluster", nil, labels),
+		RecoveringPGs:     prometheus.NewDesc(fmt.Sprintf("%s_recovering_pgs", namespace), "No. of recovering PGs in the cluster", nil, labels),
+		RecoveryWaitPGs:   prometheus.NewDesc(fmt.Sprintf("%s_recovery_wait_pgs", namespace), "No. of PGs in the cluster with recovery_wait state", nil, labels),
+		BackfillingPGs:    prometheus.NewDesc(fmt.Sprintf("%s_backfilling_pgs", namespace), "No. of backfilling PGs in the cluster", nil, labels),
+		BackfillWaitPGs:   prometheus.NewDesc(fmt.Sprintf("%s_backfill_wait_pgs", namespace), "No. of PGs in the cluster with backfill_wait state", nil, labels),
+		ForcedRecoveryPGs: prometheus.NewDesc(fmt.Sprintf("%s_forced_recovery_pgs", namespace), "No. of PGs in the cluster with forced_recovery state", nil, labels),
+		ForcedBackfillPGs: prometheus.NewDesc(fmt.Sprintf("%s_forced_backfill_pgs", namespace), "No. of PGs in the cluster with forced_backfill state", nil, labels),
+		DownPGs:           prometheus.NewDesc(fmt.Sprintf("%s_down_pgs", namespace), "No. of PGs in the cluster in down state", nil, labels),
+		IncompletePGs:     prometheus.NewDesc(fmt.Sprintf("%s_incomplete_pgs", namespace), "No. of PGs in the cluster in incomplete state", nil, labels),
+		InconsistentPGs:   prometheus.NewDesc(fmt.Sprintf("%s_inconsistent_pgs", namespace), "No. of PGs in the cluster in inconsistent state", nil, labels),
+		SnaptrimPGs:       prometheus.NewDesc(fmt.Sprintf("%s_snaptrim_pgs", namespace), "No. of snaptrim PGs in the cluster", nil, labels),
+		SnaptrimWaitPGs:   prometheus.NewDesc(fmt.Sprintf("%s_snaptrim_wait_pgs", namespace), "No. of PGs in the cluster with snaptrim_wait state", nil, labels),
+		RepairingPGs:      prometheus.NewDesc(fmt.Sprintf("%s_repairing_pgs", namespace), "No. of PGs in the cluster with repair state", nil, labels),
+
 		// with Nautilus, SLOW_OPS has replaced both REQUEST_SLOW and REQUEST_STUCK
 		// therefore slow_requests is deprecated, but for backwards compatibility
 		// the metric name will be kept the same for the time being
-		SlowOps:               prometheus.NewDesc(fmt.Sprintf("%s_slow_requests", cephNamespace), "No. of slow requests/slow ops", nil, labels),
-		DegradedPGs:           prometheus.NewDesc(fmt.Sprintf("%s_degraded_pgs", cephNamespace), "No. of PGs in a degraded state", nil, labels),
-		StuckDegradedPGs:      prometheus.NewDesc(fmt.Sprintf("%s_stuck_degraded_pgs", cephNamespace), "No. of PGs stuck in a degraded state", nil, labels),
-		UncleanPGs:            prometheus.NewDesc(fmt.Sprintf("%s_unclean_pgs", cephNamespace), "No. of PGs in an unclean state", nil, labels),
-		StuckUncleanPGs:       prometheus.NewDesc(fmt.Sprintf("%s_stuck_unclean_pgs", cephNamespace), "No. of PGs stuck in an unclean state", nil, labels),
-		UndersizedPGs:         prometheus.NewDesc(fmt.Sprintf("%s_undersized_pgs", cephNamespace), "No. of undersized PGs in the cluster", nil, labels),
-		StuckUndersizedPGs:    prometheus.NewDesc(fmt.Sprintf("%s_stuck_undersized_pgs", cephNamespace), "No. of stuck undersized PGs in the cluster", nil, labels),
-		StalePGs:              prometheus.NewDesc(fmt.Sprintf("%s_stale_pgs", cephNamespace), "No. of stale PGs in the cluster", nil, labels),
-		StuckStalePGs:         prometheus.NewDesc(fmt.Sprintf("%s_stuck_stale_pgs", cephNamespace), "No. of stuck stale PGs in the cluster", nil, labels),
-		PeeringPGs:            prometheus.NewDesc(fmt.Sprintf("%s_peering_pgs", cephNamespace), "No. of peering PGs in the cluster", nil, labels),
-		DegradedObjectsCount:  prometheus.NewDesc(fmt.Sprintf("%s_degraded_objects", cephNamespace), "No. of degraded objects across all PGs, includes replicas", nil, labels),
-		MisplacedObjectsCount: prometheus.NewDesc(fmt.Sprintf("%s_misplaced_objects", cephNamespace), "No. of misplaced objects across all PGs, includes replicas", nil, labels),
-		MisplacedRatio:        prometheus.NewDesc(fmt.Sprintf("%s_misplaced_ratio", cephNamespace), "ratio of misplaced objects to total objects", nil, labels),
-		NewCrashReportCount:   prometheus.NewDesc(fmt.Sprintf("%s_new_crash_reports", cephNamespace), "Number of new crash reports available", nil, labels),
-		TooManyRepairs:        prometheus.NewDesc(fmt.Sprintf("%s_osds_too_many_repair", cephNamespace), "Number of OSDs with too many repaired reads", nil, labels),
-		Objects:               prometheus.NewDesc(fmt.Sprintf("%s_cluster_objects", cephNamespace), "No. of rados objects within the cluster", nil, labels),
+		SlowOps:               prometheus.NewDesc(fmt.Sprintf("%s_slow_requests", namespace), "No. of slow requests/slow ops", nil, labels),
+		OSDSlowOps:            prometheus.NewDesc(fmt.Sprintf("%s_osd_has_slow_ops", namespace), "OSD named by the SLOW_OPS health check as having slow ops", []string{"osd"}, labels),
+		SlowOpsDaemons:        prometheus.NewDesc(fmt.Sprintf("%s_slow_ops_daemons", namespace), "No. of daemons of the type named by the SLOW_OPS health check as having slow ops", []string{"daemon_type"}, labels),
+		DegradedPGs:           prometheus.NewDesc(fmt.Sprintf("%s_degraded_pgs", namespace), "No. of PGs in a degraded state", nil, labels),
+		StuckDegradedPGs:      prometheus.NewDesc(fmt.Sprintf("%s_stuck_degraded_pgs", namespace), "No. of PGs stuck in a degraded state", nil, labels),
+		UncleanPGs:            prometheus.NewDesc(fmt.Sprintf("%s_unclean_pgs", namespace), "No. of PGs in an unclean state", nil, labels),
+		StuckUncleanPGs:       prometheus.NewDesc(fmt.Sprintf("%s_stuck_unclean_pgs", namespace), "No. of PGs stuck in an unclean state", nil, labels),
+		UndersizedPGs:         prometheus.NewDesc(fmt.Sprintf("%s_undersized_pgs", namespace), "No. of undersized PGs in the cluster", nil, labels),
+		StuckUndersizedPGs:    prometheus.NewDesc(fmt.Sprintf("%s_stuck_undersized_pgs", namespace), "No. of stuck undersized PGs in the cluster", nil, labels),
+		StalePGs:              prometheus.NewDesc(fmt.Sprintf("%s_stale_pgs", namespace), "No. of stale PGs in the cluster", nil, labels),
+		StuckStalePGs:         prometheus.NewDesc(fmt.Sprintf("%s_stuck_stale_pgs", namespace), "No. of stuck stale PGs in the cluster", nil, labels),
+		PeeringPGs:            prometheus.NewDesc(fmt.Sprintf("%s_peering_pgs", namespace), "No. of peering PGs in the cluster", nil, labels),
+		DegradedObjectsCount:  prometheus.NewDesc(fmt.Sprintf("%s_degraded_objects", namespace), "No. of degraded objects across all PGs, includes replicas", nil, labels),
+		MisplacedObjectsCount: prometheus.NewDesc(fmt.Sprintf("%s_misplaced_objects", namespace), "No. of misplaced objects across all PGs, includes replicas", nil, labels),
+		MisplacedRatio:        prometheus.NewDesc(fmt.Sprintf("%s_misplaced_ratio", namespace), "ratio of misplaced objects to total objects", nil, labels),
+		DegradedRatio:         prometheus.NewDesc(fmt.Sprintf("%s_degraded_ratio", namespace), "ratio of degraded objects to total objects, includes replicas", nil, labels),
+		UnfoundObjectsCount:   prometheus.NewDesc(fmt.Sprintf("%s_unfound_objects", namespace), "No. of unfound objects across all PGs", nil, labels),
+		NewCrashReportCount:   prometheus.NewDesc(fmt.Sprintf("%s_new_crash_reports", namespace), "Number of new crash reports available", nil, labels),
+		TooManyRepairs:        prometheus.NewDesc(fmt.Sprintf("%s_osds_too_many_repair", namespace), "Number of OSDs with too many repaired reads", nil, labels),
+		Objects:               prometheus.NewDesc(fmt.Sprintf("%s_cluster_objects", namespace), "No. of rados objects within the cluster", nil, labels),
 		OSDMapFlagFull: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_full",
 				Help:        "The cluster is flagged as full and cannot service writes",
 				ConstLabels: labels,
@@ -424,7 +463,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagPauseRd: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_pauserd",
 				Help:        "Reads are paused",
 				ConstLabels: labels,
@@ -432,7 +471,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagPauseWr: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_pausewr",
 				Help:        "Writes are paused",
 				ConstLabels: labels,
@@ -440,7 +479,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoUp: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_noup",
 				Help:        "OSDs are not allowed to start",
 				ConstLabels: labels,
@@ -448,7 +487,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoDown: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_nodown",
 				Help:        "OSD failure reports are ignored, OSDs will not be marked as down",
 				ConstLabels: labels,
@@ -456,7 +495,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoIn: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_noin",
 				Help:        "OSDs that are out will not be automatically marked in",
 				ConstLabels: labels,
@@ -464,7 +503,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoOut: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_noout",
 				Help:        "OSDs will not be automatically marked out after the configured interval",
 				ConstLabels: labels,
@@ -472,7 +511,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoBackfill: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_nobackfill",
 				Help:        "OSDs will not be backfilled",
 				ConstLabels: labels,
@@ -480,7 +519,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoRecover: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_norecover",
 				Help:        "Recovery is suspended",
 				ConstLabels: labels,
@@ -488,7 +527,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoRebalance: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_norebalance",
 				Help:        "Data rebalancing is suspended",
 				ConstLabels: labels,
@@ -496,7 +535,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoScrub: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_noscrub",
 				Help:        "Scrubbing is disabled",
 				ConstLabels: labels,
@@ -504,7 +543,7 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoDeepScrub: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_nodeep_scrub",
 				Help:        "Deep scrubbing is disabled",
 				ConstLabels: labels,
@@ -512,33 +551,33 @@ func NewClusterHealthCollector(exporter *Exporter) *ClusterHealthCollector {
 		),
 		OSDMapFlagNoTierAgent: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osdmap_flag_notieragent",
 				Help:        "Cache tiering activity is suspended",
 				ConstLabels: labels,
 			},
 		),
 
-		OSDMapFlags:            prometheus.NewDesc(fmt.Sprintf("%s_osd_map_flags", cephNamespace), "A metric for all OSDMap flags", []string{"flag"}, labels),
-		OSDsDown:               prometheus.NewDesc(fmt.Sprintf("%s_osds_down", cephNamespace), "Count of OSDs that are in DOWN state", nil, labels),
-		OSDsUp:                 prometheus.NewDesc(fmt.Sprintf("%s_osds_up", cephNamespace), "Count of OSDs that are in UP state", nil, labels),
-		OSDsIn:                 prometheus.NewDesc(fmt.Sprintf("%s_osds_in", cephNamespace), "Count of OSDs that are in IN state and available to serve requests", nil, labels),
-		OSDsNum:                prometheus.NewDesc(fmt.Sprintf("%s_osds", cephNamespace), "Count of total OSDs in the cluster", nil, labels),
-		RemappedPGs:            prometheus.NewDesc(fmt.Sprintf("%s_pgs_remapped", cephNamespace), "No. of PGs that are remapped and incurring cluster-wide movement", nil, labels),
-		RecoveryIORate:         prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_bytes", cephNamespace), "Rate of bytes being recovered in cluster per second", nil, labels),
-		RecoveryIOKeys:         prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_keys", cephNamespace), "Rate of keys being recovered in cluster per second", nil, labels),
-		RecoveryIOObjects:      prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_objects", cephNamespace), "Rate of objects being recovered in cluster per second", nil, labels),
-		ClientReadBytesPerSec:  prometheus.NewDesc(fmt.Sprintf("%s_client_io_read_bytes", cephNamespace), "Rate of bytes being read by all clients per second", nil, labels),
-		ClientWriteBytesPerSec: prometheus.NewDesc(fmt.Sprintf("%s_client_io_write_bytes", cephNamespace), "Rate of bytes being written by all clients per second", nil, labels),
-		ClientIOOps:            prometheus.NewDesc(fmt.Sprintf("%s_client_io_ops", cephNamespace), "Total client ops on the cluster measured per second", nil, labels),
-		ClientIOReadOps:        prometheus.NewDesc(fmt.Sprintf("%s_client_io_read_ops", cephNamespace), "Total client read I/O ops on the cluster measured per second", nil, labels),
-		ClientIOWriteOps:       prometheus.NewDesc(fmt.Sprintf("%s_client_io_write_ops", cephNamespace), "Total client write I/O ops on the cluster measured per second", nil, labels),
-		CacheFlushIORate:       prometheus.NewDesc(fmt.Sprintf("%s_cache_flush_io_bytes", cephNamespace), "Rate of bytes being flushed from the cache pool per second", nil, labels),
-		CacheEvictIORate:       prometheus.NewDesc(fmt.Sprintf("%s_cache_evict_io_bytes", cephNamespace), "Rate of bytes being evicted from the cache pool per second", nil, labels),
-		CachePromoteIOOps:      prometheus.NewDesc(fmt.Sprintf("%s_cache_promote_io_ops", cephNamespace), "Total cache promote operations measured per second", nil, labels),
-		MgrsActive:             prometheus.NewDesc(fmt.Sprintf("%s_mgrs_active", cephNamespace), "Count of active mgrs, can be either 0 or 1", nil, labels),
-		MgrsNum:                prometheus.NewDesc(fmt.Sprintf("%s_mgrs", cephNamespace), "Total number of mgrs, including standbys", nil, labels),
-		RbdMirrorUp:            prometheus.NewDesc(fmt.Sprintf("%s_rbd_mirror_up", cephNamespace), "Alive rbd-mirror daemons", []string{"name"}, labels),
+		OSDMapFlags:            prometheus.NewDesc(fmt.Sprintf("%s_osd_map_flags", namespace), "A metric for all OSDMap flags", []string{"flag"}, labels),
+		OSDsDown:               prometheus.NewDesc(fmt.Sprintf("%s_osds_down", namespace), "Count of OSDs that are in DOWN state", nil, labels),
+		OSDsUp:                 prometheus.NewDesc(fmt.Sprintf("%s_osds_up", namespace), "Count of OSDs that are in UP state", nil, labels),
+		OSDsIn:                 prometheus.NewDesc(fmt.Sprintf("%s_osds_in", namespace), "Count of OSDs that are in IN state and available to serve requests", nil, labels),
+		OSDsNum:                prometheus.NewDesc(fmt.Sprintf("%s_osds", namespace), "Count of total OSDs in the cluster", nil, labels),
+		RemappedPGs:            prometheus.NewDesc(fmt.Sprintf("%s_pgs_remapped", namespace), "No. of PGs that are remapped and incurring cluster-wide movement", nil, labels),
+		RecoveryIORate:         prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_bytes", namespace), "Rate of bytes being recovered in cluster per second", nil, labels),
+		RecoveryIOKeys:         prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_keys", namespace), "Rate of keys being recovered in cluster per second", nil, labels),
+		RecoveryIOObjects:      prometheus.NewDesc(fmt.Sprintf("%s_recovery_io_objects", namespace), "Rate of objects being recovered in cluster per second", nil, labels),
+		ClientReadBytesPerSec:  prometheus.NewDesc(fmt.Sprintf("%s_client_io_read_bytes", namespace), "Rate of bytes being read by all clients per second", nil, labels),
+		ClientWriteBytesPerSec: prometheus.NewDesc(fmt.Sprintf("%s_client_io_write_bytes", namespace), "Rate of bytes being written by all clients per second", nil, labels),
+		ClientIOOps:            prometheus.NewDesc(fmt.Sprintf("%s_client_io_ops", namespace), "Total client ops on the cluster measured per second", nil, labels),
+		ClientIOReadOps:        prometheus.NewDesc(fmt.Sprintf("%s_client_io_read_ops", namespace), "Total client read I/O ops on the cluster measured per second", nil, labels),
+		ClientIOWriteOps:       prometheus.NewDesc(fmt.Sprintf("%s_client_io_write_ops", namespace), "Total client write I/O ops on the cluster measured per second", nil, labels),
+		CacheFlushIORate:       prometheus.NewDesc(fmt.Sprintf("%s_cache_flush_io_bytes", namespace), "Rate of bytes being flushed from the cache pool per second", nil, labels),
+		CacheEvictIORate:       prometheus.NewDesc(fmt.Sprintf("%s_cache_evict_io_bytes", namespace), "Rate of bytes being evicted from the cache pool per second", nil, labels),
+		CachePromoteIOOps:      prometheus.NewDesc(fmt.Sprintf("%s_cache_promote_io_ops", namespace), "Total cache promote operations measured per second", nil, labels),
+		MgrsActive:             prometheus.NewDesc(fmt.Sprintf("%s_mgrs_active", namespace), "Count of active mgrs, can be either 0 or 1", nil, labels),
+		MgrsNum:                prometheus.NewDesc(fmt.Sprintf("%s_mgrs", namespace), "Total number of mgrs, including standbys", nil, labels),
+		RbdMirrorUp:            prometheus.NewDesc(fmt.Sprintf("%s_rbd_mirror_up", namespace), "Alive rbd-mirror daemons", []string{"name"}, labels),
 	}
 
 	// This is here to support backwards compatibility with gauges, but also exists as a general list of possible flags
@@ -593,6 +632,8 @@ func (c *ClusterHealthCollector) descriptorList() []*prometheus.Desc {
 	return []*prometheus.Desc{
 		c.HealthStatus,
 		c.HealthStatusInterpreter.Desc(),
+		c.HealthCheck,
+		c.HealthCheckCount,
 		c.MONsDown,
 		c.TotalPGs,
 		c.DegradedPGs,
@@ -620,9 +661,13 @@ func (c *ClusterHealthCollector) descriptorList() []*prometheus.Desc {
 		c.SnaptrimWaitPGs,
 		c.RepairingPGs,
 		c.SlowOps,
+		c.OSDSlowOps,
+		c.SlowOpsDaemons,
 		c.DegradedObjectsCount,
 		c.MisplacedObjectsCount,
 		c.MisplacedRatio,
+		c.DegradedRatio,
+		c.UnfoundObjectsCount,
 		c.NewCrashReportCount,
 		c.TooManyRepairs,
 		c.Objects,
@@ -658,6 +703,7 @@ func (c *ClusterHealthCollector) descriptorList() []*prometheus.Desc {
 		c.MgrsActive,
 		c.MgrsNum,
 		c.PGState,
+		c.PGsByState,
 	}
 }
 
@@ -679,6 +725,9 @@ type cephHealthStats struct {
 			Severity string `json:"severity"`
 			Summary  struct {
 				Message string `json:"message"`
+
+				// only reported from Octopus on
+				Count *float64 `json:"count"`
 			} `json:"summary"`
 		} `json:"checks"`
 	} `json:"health"`
@@ -699,6 +748,8 @@ type cephHealthStats struct {
 		DegradedObjects         float64 `json:"degraded_objects"`
 		MisplacedObjects        float64 `json:"misplaced_objects"`
 		MisplacedRatio          float64 `json:"misplaced_ratio"`
+		DegradedRatio           float64 `json:"degraded_ratio"`
+		UnfoundObjects          float64 `json:"unfound_objects"`
 		PGsByState              []struct {
 			Count  float64 `json:"count"`
 			States string  `json:"state_name"`
@@ -769,6 +820,7 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 		stuckUndersizedRegex = regexp.MustCompile(`([\d]+) pgs stuck undersized`)
 		stuckStaleRegex      = regexp.MustCompile(`([\d]+) pgs stuck stale`)
 		slowOpsRegexNautilus = regexp.MustCompile(`([\d]+) slow ops, oldest one blocked for ([\d]+) sec`)
+		slowOpsDaemonsRegex  = regexp.MustCompile(`daemons \[([^\]]*)\]|(\S+) has slow ops`)
 		newCrashreportRegex  = regexp.MustCompile(`([\d]+) daemons have recently crashed`)
 		tooManyRepairs       = regexp.MustCompile(`Too many repaired reads on ([\d]+) OSDs`)
 		osdmapFlagsRegex     = regexp.MustCompile(`([^ ]+) flag\(s\) set`)
@@ -776,6 +828,22 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 
 	var mapEmpty = len(c.healthChecksMap) == 0
 
+	// the daemons with slow ops are named as "osd.39 has slow ops" or
+	// "daemons [osd.114,osd.116,mon.a] have slow ops."
+	slowDaemons := make(map[string]bool)
+	addSlowDaemons := func(message string) {
+		matched := slowOpsDaemonsRegex.FindStringSubmatch(message)
+		if len(matched) != 3 {
+			return
+		}
+
+		for _, daemon := range strings.Split(matched[1]+matched[2], ",") {
+			if daemon = strings.TrimSpace(daemon); daemon != "" {
+				slowDaemons[daemon] = true
+			}
+		}
+	}
+
 	for _, s := range stats.Health.Summary {
 		matched := stuckDegradedRegex.FindStringSubmatch(s.Summary)
 		if len(matched) == 2 {
@@ -820,11 +888,23 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 				return err
 			}
 			ch <- prometheus.MustNewConstMetric(c.SlowOps, prometheus.GaugeValue, float64(v))
+			addSlowDaemons(s.Summary)
 		}
 	}
 
 	// This stores OSD map flags that were found, so the rest can be set to 0
 	for k, check := range stats.Health.Checks {
+		switch check.Severity {
+		case CephHealthWarn:
+			ch <- prometheus.MustNewConstMetric(c.HealthCheck, prometheus.GaugeValue, 1, k)
+		case CephHealthErr:
+			ch <- prometheus.MustNewConstMetric(c.HealthCheck, prometheus.GaugeValue, 2, k)
+		}
+
+		if check.Summary.Count != nil {
+			ch <- prometheus.MustNewConstMetric(c.HealthCheckCount, prometheus.GaugeValue, *check.Summary.Count, k)
+		}
+
 		if k == "MON_DOWN" {
 			matched := monsDownRegex.FindStringSubmatch(check.Summary.Message)
 			if len(matched) == 3 {
@@ -844,6 +924,7 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 					return err
 				}
 				ch <- prometheus.MustNewConstMetric(c.SlowOps, prometheus.GaugeValue, float64(v))
+				addSlowDaemons(check.Summary.Message)
 			}
 		}
 
@@ -892,6 +973,21 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 		}
 	}
 
+	// osd and mon are always reported so their series don't disappear when
+	// the slow ops clear.
+	slowDaemonsByType := map[string]float64{"osd": 0, "mon": 0}
+	for daemon := range slowDaemons {
+		daemonType := strings.SplitN(daemon, ".", 2)[0]
+		slowDaemonsByType[daemonType]++
+
+		if daemonType == "osd" {
+			ch <- prometheus.MustNewConstMetric(c.OSDSlowOps, prometheus.GaugeValue, 1, daemon)
+		}
+	}
+	for daemonType, count := range slowDaemonsByType {
+		ch <- prometheus.MustNewConstMetric(c.SlowOpsDaemons, prometheus.GaugeValue, count, daemonType)
+	}
+
 	var (
 		degradedPGs       float64
 		activePGs         float64
@@ -960,7 +1056,10 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 		}
 	)
 
+	pgsByState := make(map[string]float64)
 	for _, p := range stats.PGMap.PGsByState {
+		pgsByState[p.States] += p.Count
+
 		for pgState := range pgStateCounterMap {
 			if strings.Contains(p.States, pgState) {
 				*pgStateCounterMap[pgState] += p.Count
@@ -968,6 +1067,10 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 		}
 	}
 
+	for state, count := range pgsByState {
+		ch <- prometheus.MustNewConstMetric(c.PGsByState, prometheus.GaugeValue, count, state)
+	}
+
 	for state, gauge := range pgStateGaugeMap {
 		val := *pgStateCounterMap[state]
 		if state == "scrubbing" {
@@ -997,8 +1100,16 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 	ch <- prometheus.MustNewConstMetric(c.CacheFlushIORate, prometheus.GaugeValue, stats.PGMap.CacheFlushBytePerSec)
 	ch <- prometheus.MustNewConstMetric(c.CachePromoteIOOps, prometheus.GaugeValue, stats.PGMap.CachePromoteOpPerSec)
 
+	// the osdmap and mgrmap sections changed layout in Octopus; without a
+	// known version, tell them apart by the nesting of the osdmap
+	octopusLayout := c.version.IsAtLeast(Octopus)
+	if c.version == nil {
+		_, nested := stats.OSDMap["osdmap"]
+		octopusLayout = !nested
+	}
+
 	var actualOsdMap osdMap
-	if c.version.IsAtLeast(Octopus) {
+	if octopusLayout {
 		if stats.OSDMap != nil {
 			actualOsdMap = osdMap{
 				NumOSDs:        stats.OSDMap["num_osds"].(float64),
@@ -1035,10 +1146,12 @@ func (c *ClusterHealthCollector) collect(ch chan<- prometheus.Metric) error {
 	ch <- prometheus.MustNewConstMetric(c.DegradedObjectsCount, prometheus.GaugeValue, stats.PGMap.DegradedObjects)
 	ch <- prometheus.MustNewConstMetric(c.MisplacedObjectsCount, prometheus.GaugeValue, stats.PGMap.MisplacedObjects)
 	ch <- prometheus.MustNewConstMetric(c.MisplacedRatio, prometheus.GaugeValue, stats.PGMap.MisplacedRatio)
+	ch <- prometheus.MustNewConstMetric(c.DegradedRatio, prometheus.GaugeValue, stats.PGMap.DegradedRatio)
+	ch <- prometheus.MustNewConstMetric(c.UnfoundObjectsCount, prometheus.GaugeValue, stats.PGMap.UnfoundObjects)
 
 	activeMgr := 0
 	standByMgrs := 0
-	if c.version.IsAtLeast(Octopus) {
+	if octopusLayout {
 		if stats.MgrMap.Available {
 			activeMgr = 1
 		}
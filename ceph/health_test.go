@@ -33,10 +33,11 @@ func TestClusterHealthCollector(t *testing.T) {
 	nautilusOnly := []*Version{Nautilus}
 	octopusPlus := []*Version{Octopus, Pacific}
 	for _, tt := range []struct {
-		name     string
-		versions []*Version // Defaults to allVersions if not provided.
-		input    string
-		reMatch  []*regexp.Regexp
+		name      string
+		versions  []*Version // Defaults to allVersions if not provided.
+		input     string
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
 	}{
 		{
 			name: "15 pgs stuck degraded",
@@ -98,6 +99,32 @@ func TestClusterHealthCollector(t *testing.T) {
 				regexp.MustCompile(`misplaced_objects{cluster="ceph"} 20`),
 			},
 		},
+		{
+			name: "3 unfound objects",
+			input: `
+{
+	"pgmap": { "num_objects": 600, "degraded_objects": 30, "degraded_total": 1800, "degraded_ratio": 0.016667, "unfound_objects": 3, "unfound_total": 600, "unfound_ratio": 0.005 }
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`unfound_objects{cluster="ceph"} 3`),
+				regexp.MustCompile(`degraded_ratio{cluster="ceph"} 0.016667`),
+				regexp.MustCompile(`cluster_objects{cluster="ceph"} 600`),
+			},
+		},
+		{
+			name: "no recovery in progress",
+			input: `
+{
+	"pgmap": { "num_pgs": 1024 }
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`recovery_io_bytes{cluster="ceph"} 0`),
+				regexp.MustCompile(`recovery_io_objects{cluster="ceph"} 0`),
+				regexp.MustCompile(`misplaced_objects{cluster="ceph"} 0`),
+				regexp.MustCompile(`unfound_objects{cluster="ceph"} 0`),
+				regexp.MustCompile(`degraded_ratio{cluster="ceph"} 0`),
+			},
+		},
 		{
 			name:     "10 down osds",
 			versions: nautilusOnly,
@@ -314,6 +341,39 @@ $ sudo ceph -s
 				regexp.MustCompile(`repairing_pgs{cluster="ceph"} 1`),
 			},
 		},
+		{
+			name: "health checks",
+			input: `
+{
+  "health": {
+    "status": "HEALTH_ERR",
+    "checks": {
+      "OSD_DOWN": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "2 osds down",
+          "count": 2
+        }
+      },
+      "MON_DISK_CRIT": {
+        "severity": "HEALTH_ERR",
+        "summary": {
+          "message": "mon a is very low on available space"
+        }
+      }
+    }
+  }
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`health_check{check="OSD_DOWN",cluster="ceph"} 1`),
+				regexp.MustCompile(`health_check_count{check="OSD_DOWN",cluster="ceph"} 2`),
+				regexp.MustCompile(`health_check{check="MON_DISK_CRIT",cluster="ceph"} 2`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`health_check_count{check="MON_DISK_CRIT"`),
+				regexp.MustCompile(`health_check{check="PG_DEGRADED"`),
+			},
+		},
 		{
 			name: "mon down",
 			input: `
@@ -348,6 +408,7 @@ $ sudo ceph -s
 }`,
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`slow_requests{cluster="ceph"} 3`),
+				regexp.MustCompile(`osd_has_slow_ops{cluster="ceph",osd="osd.39"} 1`),
 			},
 		},
 		{
@@ -367,6 +428,7 @@ $ sudo ceph -s
 }`,
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`slow_requests{cluster="ceph"} 3`),
+				regexp.MustCompile(`osd_has_slow_ops{cluster="ceph",osd="osd.39"} 1`),
 			},
 		},
 		{
@@ -386,6 +448,32 @@ $ sudo ceph -s
 }`,
 			reMatch: []*regexp.Regexp{
 				regexp.MustCompile(`slow_requests{cluster="ceph"} 18`),
+				regexp.MustCompile(`osd_has_slow_ops{cluster="ceph",osd="osd.114"} 1`),
+				regexp.MustCompile(`osd_has_slow_ops{cluster="ceph",osd="osd.53"} 1`),
+				regexp.MustCompile(`slow_ops_daemons{cluster="ceph",daemon_type="osd"} 7`),
+				regexp.MustCompile(`slow_ops_daemons{cluster="ceph",daemon_type="mon"} 0`),
+			},
+		},
+		{
+			name: "slow ops on osds and mons",
+			input: `
+{
+  "health": {
+    "checks": {
+      "SLOW_OPS": {
+        "severity": "HEALTH_WARN",
+        "summary": {
+          "message": "5 slow ops, oldest one blocked for 32 sec, daemons [mon.a,osd.2,osd.7] have slow ops."
+        }
+      }
+    }
+  }
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`slow_requests{cluster="ceph"} 5`),
+				regexp.MustCompile(`osd_has_slow_ops{cluster="ceph",osd="osd.7"} 1`),
+				regexp.MustCompile(`slow_ops_daemons{cluster="ceph",daemon_type="osd"} 2`),
+				regexp.MustCompile(`slow_ops_daemons{cluster="ceph",daemon_type="mon"} 1`),
 			},
 		},
 		{
@@ -656,6 +744,9 @@ $ sudo ceph -s
 				regexp.MustCompile(`cache_promote_io_ops{cluster="ceph"} 1000`),
 
 				regexp.MustCompile(`pg_state{cluster="ceph",state="active"} 44`),
+				regexp.MustCompile(`pgs_by_state{cluster="ceph",state="activating\+stale\+unclean"} 30`),
+				regexp.MustCompile(`pgs_by_state{cluster="ceph",state="scrubbing\+deep"} 10`),
+				regexp.MustCompile(`pgs_by_state{cluster="ceph",state="active\+undersized\+remapped\+backfill_wait\+forced_backfill"} 10`),
 				regexp.MustCompile(`pg_state{cluster="ceph",state="degraded"} 40`),
 				regexp.MustCompile(`pg_state{cluster="ceph",state="unclean"} 30`),
 				regexp.MustCompile(`pg_state{cluster="ceph",state="undersized"} 52`),
@@ -822,6 +913,11 @@ $ sudo ceph -s
 							t.Errorf("expected %s to match\n", re.String())
 						}
 					}
+					for _, re := range tt.reUnmatch {
+						if re.Match(buf) {
+							t.Errorf("expected %s not to match\n", re.String())
+						}
+					}
 				})
 			}
 		})
@@ -0,0 +1,157 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// MgrCollector reports which mgr daemon is active and which mgr modules are
+// enabled, to catch a module such as prometheus or balancer that got
+// disabled.
+type MgrCollector struct {
+	conn    Conn
+	logger  *logrus.Logger
+	version *Version
+
+	// MgrActive shows for each mgr daemon whether it is the active one (1)
+	// or a standby (0).
+	MgrActive *prometheus.Desc
+
+	// ModuleEnabled shows for each mgr module whether it is enabled, which
+	// always on modules always are.
+	ModuleEnabled *prometheus.Desc
+}
+
+// NewMgrCollector creates a new MgrCollector instance.
+func NewMgrCollector(exporter *Exporter) *MgrCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return &MgrCollector{
+		conn:    exporter.Conn,
+		logger:  exporter.Logger,
+		version: exporter.Version,
+
+		MgrActive: prometheus.NewDesc(
+			fmt.Sprintf("%s_mgr_active", namespace),
+			"Whether the mgr daemon is the active one (1) or a standby (0)",
+			[]string{"mgr"},
+			labels,
+		),
+		ModuleEnabled: prometheus.NewDesc(
+			fmt.Sprintf("%s_mgr_module_enabled", namespace),
+			"Whether the mgr module is enabled",
+			[]string{"module"},
+			labels,
+		),
+	}
+}
+
+type cephMgrDump struct {
+	ActiveName string `json:"active_name"`
+	StandBys   []struct {
+		Name string `json:"name"`
+	} `json:"standbys"`
+}
+
+type cephMgrModuleLs struct {
+	AlwaysOnModules []string `json:"always_on_modules"`
+	EnabledModules  []string `json:"enabled_modules"`
+	DisabledModules []struct {
+		Name string `json:"name"`
+	} `json:"disabled_modules"`
+}
+
+func (m *MgrCollector) monCommand(prefix string, v interface{}) error {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": prefix,
+		"format": jsonFormat,
+	})
+	if err != nil {
+		return err
+	}
+
+	buf, _, err := m.conn.MonCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(buf, v)
+}
+
+func (m *MgrCollector) collectDaemons(ch chan<- prometheus.Metric) error {
+	var dump cephMgrDump
+	if err := m.monCommand("mgr dump", &dump); err != nil {
+		return err
+	}
+
+	// active_name is empty while no mgr is active
+	if dump.ActiveName != "" {
+		ch <- prometheus.MustNewConstMetric(m.MgrActive, prometheus.GaugeValue, 1, dump.ActiveName)
+	}
+	for _, standBy := range dump.StandBys {
+		ch <- prometheus.MustNewConstMetric(m.MgrActive, prometheus.GaugeValue, 0, standBy.Name)
+	}
+
+	return nil
+}
+
+func (m *MgrCollector) collectModules(ch chan<- prometheus.Metric) error {
+	var ls cephMgrModuleLs
+	if err := m.monCommand("mgr module ls", &ls); err != nil {
+		return err
+	}
+
+	modules := make(map[string]float64)
+	for _, module := range ls.DisabledModules {
+		modules[module.Name] = 0
+	}
+	for _, module := range append(ls.AlwaysOnModules, ls.EnabledModules...) {
+		modules[module] = 1
+	}
+
+	for module, enabled := range modules {
+		ch <- prometheus.MustNewConstMetric(m.ModuleEnabled, prometheus.GaugeValue, enabled, module)
+	}
+
+	return nil
+}
+
+// Describe sends the descriptors of the MgrCollector metrics to the provided
+// channel.
+func (m *MgrCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.MgrActive
+	ch <- m.ModuleEnabled
+}
+
+// Collect sends the state of the mgr daemons and modules to the provided
+// channel.
+func (m *MgrCollector) Collect(ch chan<- prometheus.Metric) {
+	m.logger.Debug("collecting mgr daemon metrics")
+	if err := m.collectDaemons(ch); err != nil {
+		m.logger.WithError(err).Error("error collecting mgr daemon metrics")
+	}
+
+	m.logger.Debug("collecting mgr module metrics")
+	if err := m.collectModules(ch); err != nil {
+		m.logger.WithError(err).Error("error collecting mgr module metrics")
+	}
+}
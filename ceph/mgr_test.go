@@ -0,0 +1,121 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMgrCollector(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		dump               string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name: "active and standbys",
+			dump: `
+{
+	"epoch": 112,
+	"active_gid": 4153,
+	"active_name": "ceph-mgr-a",
+	"available": true,
+	"standbys": [
+		{"gid": 4161, "name": "ceph-mgr-b"},
+		{"gid": 4172, "name": "ceph-mgr-c"}
+	]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mgr_active{cluster="ceph",mgr="ceph-mgr-a"} 1`),
+				regexp.MustCompile(`ceph_mgr_active{cluster="ceph",mgr="ceph-mgr-b"} 0`),
+				regexp.MustCompile(`ceph_mgr_active{cluster="ceph",mgr="ceph-mgr-c"} 0`),
+			},
+		},
+		{
+			name: "no active mgr",
+			dump: `
+{
+	"epoch": 113,
+	"active_gid": 0,
+	"active_name": "",
+	"available": false,
+	"standbys": [
+		{"gid": 4161, "name": "ceph-mgr-b"}
+	]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mgr_active{cluster="ceph",mgr="ceph-mgr-b"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_mgr_active{cluster="ceph",mgr=""}`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "mgr dump"
+			})).Return([]byte(tt.dump), "", nil)
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "mgr module ls"
+			})).Return([]byte(`
+{
+	"always_on_modules": ["balancer", "crash", "devicehealth"],
+	"enabled_modules": ["iostat", "prometheus"],
+	"disabled_modules": [
+		{"name": "dashboard", "can_run": true, "error_string": ""},
+		{"name": "telemetry", "can_run": true, "error_string": ""}
+	]
+}`), "", nil)
+
+			collector := NewMgrCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+
+			reg := prometheus.NewRegistry()
+			require.NoError(t, reg.Register(collector))
+
+			server := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			reMatch := append(tt.reMatch,
+				regexp.MustCompile(`ceph_mgr_module_enabled{cluster="ceph",module="balancer"} 1`),
+				regexp.MustCompile(`ceph_mgr_module_enabled{cluster="ceph",module="prometheus"} 1`),
+				regexp.MustCompile(`ceph_mgr_module_enabled{cluster="ceph",module="dashboard"} 0`),
+			)
+			for _, re := range reMatch {
+				require.True(t, re.Match(buf), re.String())
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf), re.String())
+			}
+		})
+	}
+}
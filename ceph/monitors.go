@@ -17,6 +17,7 @@ package ceph
 import (
 	"encoding/json"
 	"regexp"
+	"time"
 
 	"github.com/Jeffail/gabs"
 	"github.com/prometheus/client_golang/prometheus"
@@ -61,6 +62,10 @@ type MonitorCollector struct {
 	// Latency displays the time the monitors take to communicate between themselves.
 	Latency *prometheus.GaugeVec
 
+	// CommandLatency shows how long the monitor the exporter is connected to
+	// took to answer its `status` command, as seen by the exporter.
+	CommandLatency prometheus.Gauge
+
 	// NodesinQuorum show the size of the working monitor quorum. Any change in this
 	// metric can imply a significant issue in the cluster if it is not manually changed.
 	NodesinQuorum prometheus.Gauge
@@ -94,6 +99,7 @@ type Store struct {
 func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 
 	return &MonitorCollector{
 		conn:    exporter.Conn,
@@ -102,7 +108,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 
 		TotalKBs: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "monitor_capacity_bytes",
 				Help:        "Total storage capacity of the monitor node",
 				ConstLabels: labels,
@@ -111,7 +117,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		),
 		UsedKBs: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "monitor_used_bytes",
 				Help:        "Storage of the monitor node that is currently allocated for use",
 				ConstLabels: labels,
@@ -120,7 +126,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		),
 		AvailKBs: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "monitor_avail_bytes",
 				Help:        "Total unused storage capacity that the monitor node has left",
 				ConstLabels: labels,
@@ -129,7 +135,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		),
 		PercentAvail: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "monitor_avail_percent",
 				Help:        "Percentage of total unused storage capacity that the monitor node has left",
 				ConstLabels: labels,
@@ -139,7 +145,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		Store: Store{
 			TotalBytes: prometheus.NewGaugeVec(
 				prometheus.GaugeOpts{
-					Namespace:   cephNamespace,
+					Namespace:   namespace,
 					Name:        "monitor_store_capacity_bytes",
 					Help:        "Total capacity of the FileStore backing the monitor daemon",
 					ConstLabels: labels,
@@ -148,7 +154,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 			),
 			SSTBytes: prometheus.NewGaugeVec(
 				prometheus.GaugeOpts{
-					Namespace:   cephNamespace,
+					Namespace:   namespace,
 					Name:        "monitor_store_sst_bytes",
 					Help:        "Capacity of the FileStore used only for raw SSTs",
 					ConstLabels: labels,
@@ -157,7 +163,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 			),
 			LogBytes: prometheus.NewGaugeVec(
 				prometheus.GaugeOpts{
-					Namespace:   cephNamespace,
+					Namespace:   namespace,
 					Name:        "monitor_store_log_bytes",
 					Help:        "Capacity of the FileStore used only for logging",
 					ConstLabels: labels,
@@ -166,7 +172,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 			),
 			MiscBytes: prometheus.NewGaugeVec(
 				prometheus.GaugeOpts{
-					Namespace:   cephNamespace,
+					Namespace:   namespace,
 					Name:        "monitor_store_misc_bytes",
 					Help:        "Capacity of the FileStore used only for storing miscellaneous information",
 					ConstLabels: labels,
@@ -176,7 +182,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		},
 		ClockSkew: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "monitor_clock_skew_seconds",
 				Help:        "Clock skew the monitor node is incurring",
 				ConstLabels: labels,
@@ -185,16 +191,24 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		),
 		Latency: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "monitor_latency_seconds",
 				Help:        "Latency the monitor node is incurring",
 				ConstLabels: labels,
 			},
 			[]string{"monitor"},
 		),
+		CommandLatency: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "monitor_command_latency_seconds",
+				Help:        "Round trip time of a mon command sent by the exporter",
+				ConstLabels: labels,
+			},
+		),
 		NodesinQuorum: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "monitor_quorum_count",
 				Help:        "The total size of the monitor quorum",
 				ConstLabels: labels,
@@ -202,7 +216,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		),
 		CephVersions: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "versions",
 				Help:        "Counts of current versioned daemons, parsed from `ceph versions`",
 				ConstLabels: labels,
@@ -211,7 +225,7 @@ func NewMonitorCollector(exporter *Exporter) *MonitorCollector {
 		),
 		CephFeatures: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "features",
 				Help:        "Counts of current client features, parsed from `ceph features`",
 				ConstLabels: labels,
@@ -243,6 +257,7 @@ func (m *MonitorCollector) collectorList() []prometheus.Collector {
 func (m *MonitorCollector) metricsList() []prometheus.Metric {
 	return []prometheus.Metric{
 		m.NodesinQuorum,
+		m.CommandLatency,
 	}
 }
 
@@ -294,6 +309,7 @@ type cephFeatureGroup struct {
 func (m *MonitorCollector) collect() error {
 	// Ceph usage
 	cmd := m.cephUsageCommand()
+	start := time.Now()
 	buf, _, err := m.conn.MonCommand(cmd)
 	if err != nil {
 		m.logger.WithError(err).WithField(
@@ -302,6 +318,7 @@ func (m *MonitorCollector) collect() error {
 
 		return err
 	}
+	m.CommandLatency.Set(time.Since(start).Seconds())
 
 	stats := &cephMonitorStats{}
 	if err := json.Unmarshal(buf, stats); err != nil {
@@ -232,6 +232,7 @@ func TestMonitorCollector(t *testing.T) {
 				regexp.MustCompile(`ceph_monitor_latency_seconds{cluster="ceph",monitor="test-mon04"} 0.000609`),
 				regexp.MustCompile(`ceph_monitor_latency_seconds{cluster="ceph",monitor="test-mon05"} 0.000659`),
 				regexp.MustCompile(`ceph_monitor_quorum_count{cluster="ceph"} 5`),
+				regexp.MustCompile(`ceph_monitor_command_latency_seconds{cluster="ceph"} [0-9.e+-]+`),
 				regexp.MustCompile(`ceph_monitor_store_log_bytes{cluster="ceph",monitor="test-mon01"} 609694`),
 				regexp.MustCompile(`ceph_monitor_store_log_bytes{cluster="ceph",monitor="test-mon02"} 871605`),
 				regexp.MustCompile(`ceph_monitor_store_log_bytes{cluster="ceph",monitor="test-mon03"} 871605`),
@@ -19,7 +19,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -36,6 +38,20 @@ const (
 	scrubStateDeepScrubbing = 2
 )
 
+// osdLabelCacheTTL is how long the CRUSH location and device class of the OSDs
+// are reused before querying the OSD tree again.
+const osdLabelCacheTTL = 5 * time.Minute
+
+// osdLabelCache keeps the OSD labels between collections, as the CRUSH map
+// rarely changes. It is refreshed ahead of time when an OSD it doesn't know
+// of shows up, unless that OSD was already missing from the last refresh.
+type osdLabelCache struct {
+	mu      sync.Mutex
+	labels  map[int64]*cephOSDLabel
+	missing map[int64]bool
+	at      time.Time
+}
+
 // OSDCollector displays statistics about OSD in the Ceph cluster.
 // An important aspect of monitoring OSDs is to ensure that when the cluster is
 // up and running that all OSDs that are in the cluster are up and running, too
@@ -44,6 +60,9 @@ type OSDCollector struct {
 	logger  *logrus.Logger
 	version *Version
 
+	// labelCache is shared with the collectors of later collections
+	labelCache *osdLabelCache
+
 	// osdScrubCache holds the cache of previous PG scrubs
 	osdScrubCache map[int]int
 
@@ -57,6 +76,9 @@ type OSDCollector struct {
 	// pgDumpBrief holds the content of PG dump brief
 	pgDumpBrief cephPGDumpBrief
 
+	// poolNames maps pool ids to names, as found in the last OSD dump
+	poolNames map[int64]string
+
 	// CrushWeight is a persistent setting, and it affects how CRUSH assigns data to OSDs.
 	// It displays the CRUSH weight for the OSD
 	CrushWeight *prometheus.GaugeVec
@@ -150,6 +172,10 @@ type OSDCollector struct {
 	// (such as when issuing a bunch of upmaps or weight changes) and a single PG
 	// stuck peering, for example.
 	OldestInactivePG prometheus.Gauge
+
+	// PoolUndersizedPGs displays the number of PGs of a pool with fewer
+	// copies or shards than the pool is configured with.
+	PoolUndersizedPGs *prometheus.GaugeVec
 }
 
 // This ensures OSDCollector implements interface prometheus.Collector.
@@ -160,6 +186,7 @@ var _ prometheus.Collector = &OSDCollector{}
 func NewOSDCollector(exporter *Exporter) *OSDCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 	osdLabels := []string{"osd", "device_class", "host", "rack", "root"}
 
 	return &OSDCollector{
@@ -167,13 +194,15 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 		logger:  exporter.Logger,
 		version: exporter.Version,
 
+		labelCache: exporter.osdLabels,
+
 		osdScrubCache:       make(map[int]int),
 		osdLabelsCache:      make(map[int64]*cephOSDLabel),
 		oldestInactivePGMap: make(map[string]time.Time),
 
 		CrushWeight: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_crush_weight",
 				Help:        "OSD Crush Weight",
 				ConstLabels: labels,
@@ -183,7 +212,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		Depth: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_depth",
 				Help:        "OSD Depth",
 				ConstLabels: labels,
@@ -193,7 +222,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		Reweight: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_reweight",
 				Help:        "OSD Reweight",
 				ConstLabels: labels,
@@ -203,7 +232,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		Bytes: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_bytes",
 				Help:        "OSD Total Bytes",
 				ConstLabels: labels,
@@ -213,7 +242,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		UsedBytes: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_used_bytes",
 				Help:        "OSD Used Storage in Bytes",
 				ConstLabels: labels,
@@ -223,7 +252,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		AvailBytes: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_avail_bytes",
 				Help:        "OSD Available Storage in Bytes",
 				ConstLabels: labels,
@@ -233,7 +262,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		Utilization: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_utilization",
 				Help:        "OSD Utilization",
 				ConstLabels: labels,
@@ -243,7 +272,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		Variance: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_variance",
 				Help:        "OSD Variance",
 				ConstLabels: labels,
@@ -253,7 +282,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		Pgs: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_pgs",
 				Help:        "OSD Placement Group Count",
 				ConstLabels: labels,
@@ -263,7 +292,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		PgUpmapItemsTotal: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_pg_upmap_items_total",
 				Help:        "OSD PG-Upmap Exception Table Entry Count",
 				ConstLabels: labels,
@@ -272,7 +301,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		TotalBytes: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_total_bytes",
 				Help:        "OSD Total Storage Bytes",
 				ConstLabels: labels,
@@ -280,7 +309,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 		),
 		TotalUsedBytes: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_total_used_bytes",
 				Help:        "OSD Total Used Storage Bytes",
 				ConstLabels: labels,
@@ -289,7 +318,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		TotalAvailBytes: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_total_avail_bytes",
 				Help:        "OSD Total Available Storage Bytes ",
 				ConstLabels: labels,
@@ -298,7 +327,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		AverageUtil: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_average_utilization",
 				Help:        "OSD Average Utilization",
 				ConstLabels: labels,
@@ -307,7 +336,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		CommitLatency: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_perf_commit_latency_seconds",
 				Help:        "OSD Perf Commit Latency",
 				ConstLabels: labels,
@@ -317,7 +346,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		ApplyLatency: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_perf_apply_latency_seconds",
 				Help:        "OSD Perf Apply Latency",
 				ConstLabels: labels,
@@ -327,7 +356,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDIn: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_in",
 				Help:        "OSD In Status",
 				ConstLabels: labels,
@@ -337,7 +366,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDUp: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_up",
 				Help:        "OSD Up Status",
 				ConstLabels: labels,
@@ -347,7 +376,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDFullRatio: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_full_ratio",
 				Help:        "OSD Full Ratio Value",
 				ConstLabels: labels,
@@ -356,7 +385,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDNearFullRatio: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_near_full_ratio",
 				Help:        "OSD Near Full Ratio Value",
 				ConstLabels: labels,
@@ -365,7 +394,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDBackfillFullRatio: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_backfill_full_ratio",
 				Help:        "OSD Backfill Full Ratio Value",
 				ConstLabels: labels,
@@ -374,7 +403,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDFull: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_full",
 				Help:        "OSD Full Status",
 				ConstLabels: labels,
@@ -384,7 +413,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDNearFull: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_near_full",
 				Help:        "OSD Near Full Status",
 				ConstLabels: labels,
@@ -394,7 +423,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDBackfillFull: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_backfill_full",
 				Help:        "OSD Backfill Full Status",
 				ConstLabels: labels,
@@ -403,21 +432,21 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 		),
 
 		OSDDownDesc: prometheus.NewDesc(
-			fmt.Sprintf("%s_osd_down", cephNamespace),
+			fmt.Sprintf("%s_osd_down", namespace),
 			"Number of OSDs down in the cluster",
 			append([]string{"status"}, osdLabels...),
 			labels,
 		),
 
 		ScrubbingStateDesc: prometheus.NewDesc(
-			fmt.Sprintf("%s_osd_scrub_state", cephNamespace),
+			fmt.Sprintf("%s_osd_scrub_state", namespace),
 			"State of OSDs involved in a scrub",
 			osdLabels,
 			labels,
 		),
 
 		PGObjectsRecoveredDesc: prometheus.NewDesc(
-			fmt.Sprintf("%s_pg_objects_recovered", cephNamespace),
+			fmt.Sprintf("%s_pg_objects_recovered", namespace),
 			"Number of objects recovered in a PG",
 			[]string{"pgid"},
 			labels,
@@ -425,7 +454,7 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OSDObjectsBackfilled: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "osd_objects_backfilled",
 				Help:        "Average number of objects backfilled in an OSD",
 				ConstLabels: labels,
@@ -435,12 +464,22 @@ func NewOSDCollector(exporter *Exporter) *OSDCollector {
 
 		OldestInactivePG: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "pg_oldest_inactive",
 				Help:        "The amount of time in seconds that the oldest PG has been inactive for",
 				ConstLabels: labels,
 			},
 		),
+
+		PoolUndersizedPGs: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "pool_undersized_pgs",
+				Help:        "Number of PGs of the pool with fewer copies or shards than configured",
+				ConstLabels: labels,
+			},
+			[]string{"pool"},
+		),
 	}
 }
 
@@ -472,6 +511,7 @@ func (o *OSDCollector) collectorList() []prometheus.Collector {
 		o.OSDBackfillFull,
 		o.OSDObjectsBackfilled,
 		o.OldestInactivePG,
+		o.PoolUndersizedPGs,
 	}
 }
 
@@ -507,8 +547,11 @@ type cephPerfStat struct {
 	} `json:"osd_perf_infos"`
 }
 
+// CephOSDPerfStat is the output of "osd perf". Octopus and later nest the
+// perf infos under "osdstats", while Nautilus lists them at the top level.
 type CephOSDPerfStat struct {
-	cephPerfStat `json:"osdstats"`
+	OSDStats *cephPerfStat `json:"osdstats"`
+	cephPerfStat
 }
 
 type cephOSDDump struct {
@@ -527,6 +570,11 @@ type cephOSDDump struct {
 		} `json:"mappings"`
 	} `json:"pg_upmap_items"`
 
+	Pools []struct {
+		Pool     int64  `json:"pool"`
+		PoolName string `json:"pool_name"`
+	} `json:"pools"`
+
 	FullRatio         json.Number `json:"full_ratio"`
 	NearFullRatio     json.Number `json:"nearfull_ratio"`
 	BackfillFullRatio json.Number `json:"backfillfull_ratio"`
@@ -721,13 +769,25 @@ func (o *OSDCollector) collectOSDPerf() error {
 		return err
 	}
 
-	for _, perfStat := range osdPerf.PerfInfo {
+	perfInfo := osdPerf.PerfInfo
+	if osdPerf.OSDStats != nil {
+		perfInfo = osdPerf.OSDStats.PerfInfo
+	}
+
+	for _, perfStat := range perfInfo {
 		osdID, err := perfStat.ID.Int64()
 		if err != nil {
 			return err
 		}
 		osdName := fmt.Sprintf(osdLabelFormat, osdID)
 
+		// OSDs that haven't reported their perf stats yet, such as ones that
+		// just started, are skipped rather than failing the collection.
+		if perfStat.Stats.CommitLatency == "" || perfStat.Stats.ApplyLatency == "" {
+			o.logger.WithField("osd", osdName).Debug("skipping OSD without perf stats")
+			continue
+		}
+
 		lb := o.getOSDLabelFromID(osdID)
 
 		commitLatency, err := perfStat.Stats.CommitLatency.Float64()
@@ -809,6 +869,22 @@ func buildOSDLabels(data []byte) (map[int64]*cephOSDLabel, error) {
 }
 
 func (o *OSDCollector) buildOSDLabelCache() error {
+	if o.labelCache != nil {
+		o.labelCache.mu.Lock()
+		defer o.labelCache.mu.Unlock()
+
+		if o.labelCache.labels != nil && time.Since(o.labelCache.at) < osdLabelCacheTTL {
+			o.osdLabelsCache = o.labelCache.labels
+			return nil
+		}
+	}
+
+	return o.refreshOSDLabels()
+}
+
+// refreshOSDLabels queries the OSD tree for the OSD labels. The caller must
+// hold the lock of the shared label cache, if any.
+func (o *OSDCollector) refreshOSDLabels() error {
 	cmd := o.cephOSDTreeCommand()
 	data, _, err := o.conn.MonCommand(cmd)
 	if err != nil {
@@ -824,6 +900,12 @@ func (o *OSDCollector) buildOSDLabelCache() error {
 		return err
 	}
 	o.osdLabelsCache = cache
+
+	if o.labelCache != nil {
+		o.labelCache.labels = cache
+		o.labelCache.missing = make(map[int64]bool)
+		o.labelCache.at = time.Now()
+	}
 	return nil
 }
 
@@ -831,6 +913,35 @@ func (o *OSDCollector) getOSDLabelFromID(id int64) *cephOSDLabel {
 	if label, ok := o.osdLabelsCache[id]; ok {
 		return label
 	}
+	if o.labelCache == nil {
+		return &cephOSDLabel{}
+	}
+
+	// The OSD may have been added since the labels were cached, in which
+	// case the cache is refreshed rather than labelling it empty until the
+	// cache expires.
+	o.labelCache.mu.Lock()
+	defer o.labelCache.mu.Unlock()
+
+	if label, ok := o.labelCache.labels[id]; ok {
+		o.osdLabelsCache = o.labelCache.labels
+		return label
+	}
+	if o.labelCache.missing[id] {
+		return &cephOSDLabel{}
+	}
+
+	if err := o.refreshOSDLabels(); err != nil {
+		o.logger.WithError(err).WithField("osd", id).Error("error refreshing OSD labels")
+	} else if label, ok := o.osdLabelsCache[id]; ok {
+		return label
+	}
+
+	// don't query the OSD tree again for this OSD until the cache expires
+	if o.labelCache.missing == nil {
+		o.labelCache.missing = make(map[int64]bool)
+	}
+	o.labelCache.missing[id] = true
 	return &cephOSDLabel{}
 }
 
@@ -914,6 +1025,11 @@ func (o *OSDCollector) collectOSDDump() error {
 	o.OSDBackfillFullRatio.Set(osdBackfillFullRatio)
 	o.PgUpmapItemsTotal.Set(float64(len(osdDump.PgUpmapItems)))
 
+	o.poolNames = make(map[int64]string)
+	for _, pool := range osdDump.Pools {
+		o.poolNames[pool.Pool] = pool.PoolName
+	}
+
 	for _, dumpInfo := range osdDump.OSDs {
 		osdID, err := dumpInfo.OSD.Int64()
 		if err != nil {
@@ -1106,6 +1222,37 @@ func (o *OSDCollector) collectPGStates(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
+func (o *OSDCollector) collectPoolUndersizedPGs() error {
+	undersized := make(map[string]float64)
+	for _, name := range o.poolNames {
+		undersized[name] = 0
+	}
+
+	for _, pg := range o.pgDumpBrief.PGStats {
+		if !strings.Contains(pg.State, "undersized") {
+			continue
+		}
+
+		// PG ids are made of the pool id and the PG number, e.g. 3.1f
+		poolID, err := strconv.ParseInt(strings.SplitN(pg.PGID, ".", 2)[0], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		name, ok := o.poolNames[poolID]
+		if !ok {
+			continue
+		}
+		undersized[name]++
+	}
+
+	for name, count := range undersized {
+		o.PoolUndersizedPGs.WithLabelValues(name).Set(count)
+	}
+
+	return nil
+}
+
 // Describe sends the descriptors of each OSDCollector related metrics we have
 // defined to the provided Prometheus channel.
 func (o *OSDCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -1134,6 +1281,7 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric) {
 	o.ApplyLatency.Reset()
 	o.OSDIn.Reset()
 	o.OSDUp.Reset()
+	o.PoolUndersizedPGs.Reset()
 	o.buildOSDLabelCache()
 
 	o.logger.Debug("collecting OSD perf metrics")
@@ -1157,8 +1305,9 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	o.logger.Debug("collecting PG dump metrics")
-	if err := o.performPGDumpBrief(); err != nil {
-		o.logger.WithError(err).Error("error collecting PG dump metrics")
+	pgDumpErr := o.performPGDumpBrief()
+	if pgDumpErr != nil {
+		o.logger.WithError(pgDumpErr).Error("error collecting PG dump metrics")
 	}
 
 	o.logger.Debug("collecting OSD scrub metrics")
@@ -1171,6 +1320,15 @@ func (o *OSDCollector) Collect(ch chan<- prometheus.Metric) {
 		o.logger.WithError(err).Error("error collecting PG state metrics")
 	}
 
+	// Without a PG dump every pool would report 0 undersized PGs, so leave
+	// the metric empty rather than export a misleading value.
+	if pgDumpErr == nil {
+		o.logger.Debug("collecting pool undersized PG metrics")
+		if err := o.collectPoolUndersizedPGs(); err != nil {
+			o.logger.WithError(err).Error("error collecting pool undersized PG metrics")
+		}
+	}
+
 	for _, metric := range o.collectorList() {
 		metric.Collect(ch)
 	}
@@ -16,15 +16,18 @@ package ceph
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -402,6 +405,8 @@ func TestOSDCollector(t *testing.T) {
 		regexp.MustCompile(`ceph_osd_pgs{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.3",rack="A8R1",root="default"} 164`),
 		regexp.MustCompile(`ceph_osd_pgs{cluster="ceph",device_class="ssd",host="prod-data01-block01",osd="osd.4",rack="A8R1",root="default"} 0`),
 		regexp.MustCompile(`ceph_osd_pg_upmap_items_total{cluster="ceph"} 2`),
+		regexp.MustCompile(`ceph_pool_undersized_pgs{cluster="ceph",pool="rbd"} 2`),
+		regexp.MustCompile(`ceph_pool_undersized_pgs{cluster="ceph",pool="data"} 0`),
 		regexp.MustCompile(`ceph_osd_total_bytes{cluster="ceph"} 4.5671694336e`),
 		regexp.MustCompile(`ceph_osd_total_used_bytes{cluster="ceph"} 1.5849472e`),
 		regexp.MustCompile(`ceph_osd_total_avail_bytes{cluster="ceph"} 4.5513199616e`),
@@ -711,6 +716,24 @@ func TestOSDCollector(t *testing.T) {
 			"acting_primary": 20,
 			"pgid": "83.1fff",
 			"state": "active+clean+scrubbing+deep"
+		},
+		{
+			"acting": [
+				0,
+				1
+			],
+			"acting_primary": 0,
+			"pgid": "81.0",
+			"state": "active+undersized+degraded"
+		},
+		{
+			"acting": [
+				1,
+				2
+			],
+			"acting_primary": 1,
+			"pgid": "81.1",
+			"state": "active+undersized"
 		}
 	]
 }`), "", nil)
@@ -730,6 +753,16 @@ func TestOSDCollector(t *testing.T) {
 	"full_ratio": 0.9,
 	"backfillfull_ratio": 0.8,
 	"nearfull_ratio": 0.7,
+	"pools": [
+		{
+			"pool": 81,
+			"pool_name": "rbd"
+		},
+		{
+			"pool": 82,
+			"pool_name": "data"
+		}
+	],
 	"osds": [
 		{
 			"osd": 0,
@@ -987,3 +1020,144 @@ func TestOSDCollector(t *testing.T) {
 		}()
 	}
 }
+
+func TestOSDLabelCache(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd tree"
+	})).Return([]byte(testOSDTreeOutput), "", nil)
+
+	exporter := &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), osdLabels: &osdLabelCache{}}
+
+	// the OSD tree is only queried by the first of consecutive collections
+	for i := 0; i < 2; i++ {
+		collector := NewOSDCollector(exporter)
+		require.NoError(t, collector.buildOSDLabelCache())
+		require.Equal(t, "hdd", collector.getOSDLabelFromID(0).DeviceClass)
+	}
+	conn.AssertNumberOfCalls(t, "MonCommand", 1)
+
+	// and again once the labels are too old
+	exporter.osdLabels.at = time.Now().Add(-2 * osdLabelCacheTTL)
+	require.NoError(t, NewOSDCollector(exporter).buildOSDLabelCache())
+	conn.AssertNumberOfCalls(t, "MonCommand", 2)
+
+	// OSDs added to a tree that only has osd.0
+	conn = &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd tree"
+	})).Return([]byte(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "children": [0]},
+		{"id": 0, "name": "osd.0", "type": "osd", "device_class": "hdd"}
+	],
+	"stray": []
+}`), "", nil).Once()
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd tree"
+	})).Return([]byte(`
+{
+	"nodes": [
+		{"id": -1, "name": "default", "type": "root", "children": [0, 1]},
+		{"id": 0, "name": "osd.0", "type": "osd", "device_class": "hdd"},
+		{"id": 1, "name": "osd.1", "type": "osd", "device_class": "ssd"}
+	],
+	"stray": []
+}`), "", nil)
+
+	exporter = &Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), osdLabels: &osdLabelCache{}}
+
+	collector := NewOSDCollector(exporter)
+	require.NoError(t, collector.buildOSDLabelCache())
+	conn.AssertNumberOfCalls(t, "MonCommand", 1)
+
+	// an OSD added since the labels were cached refreshes them early
+	require.Equal(t, "ssd", collector.getOSDLabelFromID(1).DeviceClass)
+	require.Equal(t, "default", collector.getOSDLabelFromID(1).Root)
+	conn.AssertNumberOfCalls(t, "MonCommand", 2)
+
+	// while an OSD missing from the refreshed labels doesn't query them again
+	for i := 0; i < 2; i++ {
+		require.Equal(t, &cephOSDLabel{}, NewOSDCollector(exporter).getOSDLabelFromID(7))
+	}
+	conn.AssertNumberOfCalls(t, "MonCommand", 3)
+
+	collector = NewOSDCollector(exporter)
+	require.NoError(t, collector.buildOSDLabelCache())
+	require.Equal(t, &cephOSDLabel{}, collector.getOSDLabelFromID(7))
+	require.Equal(t, "ssd", collector.getOSDLabelFromID(1).DeviceClass)
+	conn.AssertNumberOfCalls(t, "MonCommand", 3)
+}
+
+func TestOSDUndersizedPGsWithoutPGDump(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd dump"
+	})).Return([]byte(`
+{
+	"full_ratio": 0.9,
+	"backfillfull_ratio": 0.8,
+	"nearfull_ratio": 0.7,
+	"pools": [
+		{"pool": 81, "pool_name": "rbd"},
+		{"pool": 82, "pool_name": "data"}
+	],
+	"osds": []
+}`), "", nil)
+	conn.On("MonCommand", mock.Anything).Return([]byte(nil), "", errors.New("unavailable"))
+	conn.On("MgrCommand", mock.Anything).Return([]byte(nil), "", errors.New("unavailable"))
+
+	collector := NewOSDCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+
+	// a failed PG dump must not be reported as zero undersized PGs
+	ch := make(chan prometheus.Metric, 1024)
+	collector.Collect(ch)
+	close(ch)
+
+	require.Equal(t, 0, testutil.CollectAndCount(collector.PoolUndersizedPGs))
+}
+
+func TestOSDPerfLayouts(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "nautilus",
+			input: `
+{
+    "osd_perf_infos": [
+        {"id": 0, "perf_stats": {"commit_latency_ms": 2, "apply_latency_ms": 31}},
+        {"id": 1}
+    ]
+}`,
+		},
+		{
+			name: "octopus",
+			input: `
+{
+    "osdstats": {
+        "osd_perf_infos": [
+            {"id": 0, "perf_stats": {"commit_latency_ms": 2, "apply_latency_ms": 31}},
+            {"id": 1}
+        ]
+    }
+}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MgrCommand", mock.Anything).Return([]byte(tt.input), "", nil)
+
+			collector := NewOSDCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+			require.NoError(t, collector.collectOSDPerf())
+
+			require.Equal(t, 0.002, testutil.ToFloat64(collector.CommitLatency.WithLabelValues("osd.0", "", "", "", "")))
+			require.Equal(t, 0.031, testutil.ToFloat64(collector.ApplyLatency.WithLabelValues("osd.0", "", "", "", "")))
+
+			// osd.1 has no perf stats yet and is left out
+			require.Equal(t, 1, testutil.CollectAndCount(collector.CommitLatency))
+		})
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"errors"
 	"math"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -29,6 +31,70 @@ const (
 	poolErasure    = 3
 )
 
+// poolRemovedGrace is how long a removed pool keeps being reported as
+// removing, so that every scraper gets to see it.
+const poolRemovedGrace = 5 * time.Minute
+
+// poolSet keeps the pools found by the last collection, by pool id, so that
+// the pools removed since then can be told apart from renamed ones. It is
+// shared by every collection of the exporter, and an abandoned collector may
+// still update it while the next collection runs.
+type poolSet struct {
+	mu      sync.Mutex
+	pools   map[string]string
+	removed map[string]removedPool
+	now     func() time.Time
+}
+
+// removedPool is a pool found missing at a given time.
+type removedPool struct {
+	name string
+	at   time.Time
+}
+
+func newPoolSet() *poolSet {
+	return &poolSet{
+		pools:   make(map[string]string),
+		removed: make(map[string]removedPool),
+		now:     time.Now,
+	}
+}
+
+// update replaces the known pools with the current ones, given as names by
+// key, and returns the names of the pools removed within the last
+// poolRemovedGrace.
+func (s *poolSet) update(current map[string]string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, name := range s.pools {
+		if _, ok := current[key]; !ok {
+			s.removed[key] = removedPool{name: name, at: now}
+		}
+	}
+	s.pools = current
+
+	names := make(map[string]bool, len(current))
+	for _, name := range current {
+		names[name] = true
+	}
+
+	var removed []string
+	for key, pool := range s.removed {
+		if _, ok := current[key]; ok || now.Sub(pool.at) > poolRemovedGrace {
+			delete(s.removed, key)
+			continue
+		}
+		// a pool created again under the same name is reported as it is now
+		if !names[pool.name] {
+			removed = append(removed, pool.name)
+		}
+	}
+
+	return removed
+}
+
 // PoolInfoCollector gives information about each pool that exists in a given
 // ceph cluster.
 type PoolInfoCollector struct {
@@ -36,6 +102,11 @@ type PoolInfoCollector struct {
 	logger  *logrus.Logger
 	version *Version
 
+	// knownPools holds the pools found by the previous collection and those
+	// removed recently. It is shared with the exporter and the collectors of
+	// other collections.
+	knownPools *poolSet
+
 	// PGNum contains the count of PGs allotted to a particular pool.
 	PGNum *prometheus.GaugeVec
 
@@ -57,11 +128,44 @@ type PoolInfoCollector struct {
 	// QuotaMaxObjects contains maximum amount of RADOS objects allowed in a pool.
 	QuotaMaxObjects *prometheus.GaugeVec
 
+	// QuotaEnabled shows whether a byte or object quota is set on a pool, as
+	// Ceph reports an unset quota as a limit of 0.
+	QuotaEnabled *prometheus.GaugeVec
+
 	// StripeWidth contains width of a RADOS object in a pool.
 	StripeWidth *prometheus.GaugeVec
 
 	// ExpansionFactor Contains a float >= 1 that defines the EC or replication multiplier of a pool
 	ExpansionFactor *prometheus.GaugeVec
+
+	// CrushRule shows the ID of the CRUSH rule placing the data of a pool.
+	CrushRule *prometheus.GaugeVec
+
+	// Replication shows how a pool stores its data redundantly as labels:
+	// its type, size and min_size, and for an EC pool the data (k) and
+	// coding (m) chunks of its profile.
+	Replication *prometheus.GaugeVec
+
+	// Removing shows whether a pool has been removed within the last
+	// poolRemovedGrace; its PGs are then still being deleted by the OSDs.
+	Removing *prometheus.GaugeVec
+
+	// AutoscalePGNum shows the PG count of a pool as seen by the PG
+	// autoscaler, which is only available from Nautilus on.
+	AutoscalePGNum *prometheus.GaugeVec
+
+	// AutoscalePGNumIdeal shows the PG count the PG autoscaler would set
+	// for a pool given its usage or target size.
+	AutoscalePGNumIdeal *prometheus.GaugeVec
+
+	// AutoscaleWouldAdjust shows whether the PG count of a pool is far
+	// enough from the ideal one for the PG autoscaler to change it, or to
+	// warn about it if the autoscaler is only set to warn.
+	AutoscaleWouldAdjust *prometheus.GaugeVec
+
+	// AutoscaleBias shows the multiplier the PG autoscaler applies to the
+	// ideal PG count of a pool.
+	AutoscaleBias *prometheus.GaugeVec
 }
 
 // NewPoolInfoCollector displays information about each pool in the cluster.
@@ -73,15 +177,17 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 
 	return &PoolInfoCollector{
-		conn:    exporter.Conn,
-		logger:  exporter.Logger,
-		version: exporter.Version,
+		conn:       exporter.Conn,
+		logger:     exporter.Logger,
+		version:    exporter.Version,
+		knownPools: exporter.knownPools,
 
 		PGNum: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "pg_num",
 				Help:        "The total count of PGs alotted to a pool",
@@ -91,7 +197,7 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 		),
 		PlacementPGNum: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "pgp_num",
 				Help:        "The total count of PGs alotted to a pool and used for placements",
@@ -101,7 +207,7 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 		),
 		MinSize: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "min_size",
 				Help:        "Minimum number of copies or chunks of an object that need to be present for active I/O",
@@ -111,7 +217,7 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 		),
 		ActualSize: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "size",
 				Help:        "Total copies or chunks of an object that need to be present for a healthy cluster",
@@ -121,7 +227,7 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 		),
 		QuotaMaxBytes: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "quota_max_bytes",
 				Help:        "Maximum amount of bytes of data allowed in a pool",
@@ -131,7 +237,7 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 		),
 		QuotaMaxObjects: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "quota_max_objects",
 				Help:        "Maximum amount of RADOS objects allowed in a pool",
@@ -139,9 +245,19 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 			},
 			poolLabels,
 		),
+		QuotaEnabled: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "quota_enabled",
+				Help:        "Whether a byte or object quota is set on the pool (1) or not (0)",
+				ConstLabels: labels,
+			},
+			poolLabels,
+		),
 		StripeWidth: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "stripe_width",
 				Help:        "Stripe width of a RADOS object in a pool",
@@ -151,7 +267,7 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 		),
 		ExpansionFactor: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Subsystem:   subSystem,
 				Name:        "expansion_factor",
 				Help:        "Data expansion multiplier for a pool",
@@ -159,6 +275,76 @@ func NewPoolInfoCollector(exporter *Exporter) *PoolInfoCollector {
 			},
 			poolLabels,
 		),
+		CrushRule: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "crush_rule",
+				Help:        "ID of the CRUSH rule placing the data of the pool",
+				ConstLabels: labels,
+			},
+			poolLabels,
+		),
+		Replication: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "replication",
+				Help:        "Redundancy settings of the pool, always 1",
+				ConstLabels: labels,
+			},
+			[]string{"pool", "type", "profile", "size", "min_size", "k", "m"},
+		),
+		Removing: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "removing",
+				Help:        "Whether the pool was removed within the last few minutes (1) or still exists (0)",
+				ConstLabels: labels,
+			},
+			[]string{"pool"},
+		),
+		AutoscalePGNum: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "autoscale_pg_num",
+				Help:        "PG count of the pool as seen by the PG autoscaler",
+				ConstLabels: labels,
+			},
+			[]string{"pool"},
+		),
+		AutoscalePGNumIdeal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "autoscale_pg_num_ideal",
+				Help:        "PG count the PG autoscaler would set for the pool",
+				ConstLabels: labels,
+			},
+			[]string{"pool"},
+		),
+		AutoscaleWouldAdjust: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "autoscale_would_adjust",
+				Help:        "Whether the PG autoscaler would change the PG count of the pool (1) or not (0)",
+				ConstLabels: labels,
+			},
+			[]string{"pool"},
+		),
+		AutoscaleBias: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   subSystem,
+				Name:        "autoscale_bias",
+				Help:        "Multiplier applied by the PG autoscaler to the ideal PG count of the pool",
+				ConstLabels: labels,
+			},
+			[]string{"pool"},
+		),
 	}
 }
 
@@ -170,12 +356,21 @@ func (p *PoolInfoCollector) collectorList() []prometheus.Collector {
 		p.ActualSize,
 		p.QuotaMaxBytes,
 		p.QuotaMaxObjects,
+		p.QuotaEnabled,
 		p.StripeWidth,
 		p.ExpansionFactor,
+		p.CrushRule,
+		p.Replication,
+		p.Removing,
+		p.AutoscalePGNum,
+		p.AutoscalePGNumIdeal,
+		p.AutoscaleWouldAdjust,
+		p.AutoscaleBias,
 	}
 }
 
 type poolInfo struct {
+	ID              *int64  `json:"pool_id"`
 	Name            string  `json:"pool_name"`
 	ActualSize      float64 `json:"size"`
 	MinSize         float64 `json:"min_size"`
@@ -218,8 +413,12 @@ func (p *PoolInfoCollector) collect() error {
 	p.ActualSize.Reset()
 	p.QuotaMaxBytes.Reset()
 	p.QuotaMaxObjects.Reset()
+	p.QuotaEnabled.Reset()
 	p.StripeWidth.Reset()
 	p.ExpansionFactor.Reset()
+	p.CrushRule.Reset()
+	p.Replication.Reset()
+	p.Removing.Reset()
 
 	for _, pool := range stats.Pools {
 		if pool.Type == poolReplicated {
@@ -232,13 +431,129 @@ func (p *PoolInfoCollector) collect() error {
 		p.ActualSize.WithLabelValues(labelValues...).Set(pool.ActualSize)
 		p.QuotaMaxBytes.WithLabelValues(labelValues...).Set(pool.QuotaMaxBytes)
 		p.QuotaMaxObjects.WithLabelValues(labelValues...).Set(pool.QuotaMaxObjects)
+
+		quotaEnabled := 0.0
+		if pool.QuotaMaxBytes > 0 || pool.QuotaMaxObjects > 0 {
+			quotaEnabled = 1
+		}
+		p.QuotaEnabled.WithLabelValues(labelValues...).Set(quotaEnabled)
+
 		p.StripeWidth.WithLabelValues(labelValues...).Set(pool.StripeWidth)
-		p.ExpansionFactor.WithLabelValues(labelValues...).Set(p.getExpansionFactor(pool))
+		p.CrushRule.WithLabelValues(labelValues...).Set(float64(pool.CrushRule))
+
+		poolType := "replicated"
+		if pool.Type == poolErasure {
+			poolType = "erasure"
+		}
+
+		expansionFactor := pool.ActualSize
+		k, m, err := p.getECProfile(pool)
+		if err == nil {
+			poolType = "erasure"
+			expansionFactor = ecExpansionFactor(k, m)
+		} else {
+			// Non-EC pool (or unable to get profile info); assume that it's replicated.
+			p.logger.WithError(err).Debug("failed to get ec profile")
+		}
+		p.ExpansionFactor.WithLabelValues(labelValues...).Set(expansionFactor)
+
+		p.Replication.WithLabelValues(
+			pool.Name,
+			poolType,
+			pool.Profile,
+			strconv.FormatFloat(pool.ActualSize, 'f', -1, 64),
+			strconv.FormatFloat(pool.MinSize, 'f', -1, 64),
+			k,
+			m,
+		).Set(1)
+		p.Removing.WithLabelValues(pool.Name).Set(0)
+	}
+
+	p.trackRemovedPools(stats.Pools)
+
+	// the PG autoscaler appeared with Nautilus
+	if p.version.IsAtLeast(Nautilus) {
+		if err := p.collectAutoscaleStatus(); err != nil {
+			p.logger.WithError(err).Warn("error collecting PG autoscaler status")
+		}
+	}
+
+	return nil
+}
+
+type cephPoolAutoscaleStatus struct {
+	PoolName    string  `json:"pool_name"`
+	PGNumTarget float64 `json:"pg_num_target"`
+	PGNumFinal  float64 `json:"pg_num_final"`
+	WouldAdjust bool    `json:"would_adjust"`
+	Bias        float64 `json:"bias"`
+}
+
+func (p *PoolInfoCollector) collectAutoscaleStatus() error {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd pool autoscale-status",
+		"format": "json",
+	})
+	if err != nil {
+		return err
+	}
+
+	buf, _, err := p.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		return err
+	}
+
+	var statuses []cephPoolAutoscaleStatus
+	if err := json.Unmarshal(buf, &statuses); err != nil {
+		return err
+	}
+
+	p.AutoscalePGNum.Reset()
+	p.AutoscalePGNumIdeal.Reset()
+	p.AutoscaleWouldAdjust.Reset()
+	p.AutoscaleBias.Reset()
+
+	for _, status := range statuses {
+		wouldAdjust := 0.0
+		if status.WouldAdjust {
+			wouldAdjust = 1
+		}
+
+		p.AutoscalePGNum.WithLabelValues(status.PoolName).Set(status.PGNumTarget)
+		p.AutoscalePGNumIdeal.WithLabelValues(status.PoolName).Set(status.PGNumFinal)
+		p.AutoscaleWouldAdjust.WithLabelValues(status.PoolName).Set(wouldAdjust)
+		p.AutoscaleBias.WithLabelValues(status.PoolName).Set(status.Bias)
 	}
 
 	return nil
 }
 
+// poolKey identifies a pool by its id, which unlike its name survives a
+// rename. Releases that don't report the id fall back to the name.
+func poolKey(pool poolInfo) string {
+	if pool.ID == nil {
+		return "name:" + pool.Name
+	}
+	return strconv.FormatInt(*pool.ID, 10)
+}
+
+// trackRemovedPools reports the pools that were removed within the last
+// poolRemovedGrace, and remembers the current ones for the next collection.
+func (p *PoolInfoCollector) trackRemovedPools(pools []poolInfo) {
+	if p.knownPools == nil {
+		return
+	}
+
+	current := make(map[string]string)
+	for _, pool := range pools {
+		current[poolKey(pool)] = pool.Name
+	}
+
+	for _, name := range p.knownPools.update(current) {
+		p.Removing.WithLabelValues(name).Set(1)
+	}
+}
+
 func (p *PoolInfoCollector) cephInfoCommand() []byte {
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "osd pool ls",
@@ -273,30 +588,21 @@ func (p *PoolInfoCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
-func (p *PoolInfoCollector) getExpansionFactor(pool poolInfo) float64 {
-	ef, err := p.getECExpansionFactor(pool)
-	if err == nil {
-		return ef
-	} else {
-		// Non-EC pool (or unable to get profile info); assume that it's replicated.
-		logrus.WithError(err).Debug("failed to get ec expansion factor")
-		return pool.ActualSize
-	}
-}
-
-func (p *PoolInfoCollector) getECExpansionFactor(pool poolInfo) (float64, error) {
+// getECProfile returns the number of data (k) and coding (m) chunks of the
+// erasure code profile of the pool.
+func (p *PoolInfoCollector) getECProfile(pool poolInfo) (string, string, error) {
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "osd erasure-code-profile get",
 		"name":   pool.Profile,
 		"format": "json",
 	})
 	if err != nil {
-		return -1, err
+		return "", "", err
 	}
 
 	buf, _, err := p.conn.MonCommand(cmd)
 	if err != nil {
-		return -1, err
+		return "", "", err
 	}
 
 	type ecInfo struct {
@@ -307,19 +613,24 @@ func (p *PoolInfoCollector) getECExpansionFactor(pool poolInfo) (float64, error)
 	ecStats := ecInfo{}
 	err = json.Unmarshal(buf, &ecStats)
 	if err != nil {
-		return -1, err
+		return "", "", err
 	}
 
 	if ecStats.K == "" || ecStats.M == "" {
-		return -1, errors.New("missing stats")
+		return "", "", errors.New("missing stats")
 	}
 
-	k, _ := strconv.ParseFloat(ecStats.K, 64)
-	m, _ := strconv.ParseFloat(ecStats.M, 64)
+	return ecStats.K, ecStats.M, nil
+}
+
+// ecExpansionFactor returns the raw space taken by the data of a pool with k
+// data and m coding chunks for each byte stored.
+func ecExpansionFactor(k, m string) float64 {
+	data, _ := strconv.ParseFloat(k, 64)
+	coding, _ := strconv.ParseFloat(m, 64)
 
-	expansionFactor := (k + m) / k
-	roundedExpansion := math.Round(expansionFactor*100) / 100
-	return roundedExpansion, nil
+	expansionFactor := (data + coding) / data
+	return math.Round(expansionFactor*100) / 100
 }
 
 func (p *PoolInfoCollector) getCrushRuleToRootMappings() map[int64]string {
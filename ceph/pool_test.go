@@ -21,11 +21,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -43,8 +46,12 @@ func TestPoolInfoCollector(t *testing.T) {
 				regexp.MustCompile(`pool_pgp_num{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 8192`),
 				regexp.MustCompile(`pool_quota_max_bytes{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 1024`),
 				regexp.MustCompile(`pool_quota_max_objects{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 2048`),
+				regexp.MustCompile(`pool_quota_enabled{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 1`),
 				regexp.MustCompile(`pool_stripe_width{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 4096`),
+				regexp.MustCompile(`pool_crush_rule{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 1`),
 				regexp.MustCompile(`pool_expansion_factor{cluster="ceph",pool="rbd",profile="ec-4-2",root="non-default-root"} 1.5`),
+				regexp.MustCompile(`pool_replication{cluster="ceph",k="4",m="2",min_size="4",pool="rbd",profile="ec-4-2",size="6",type="erasure"} 1`),
+				regexp.MustCompile(`pool_replication{cluster="ceph",k="",m="",min_size="2",pool="rbd",profile="replicated-ruleset",size="3",type="replicated"} 1`),
 
 				regexp.MustCompile(`pool_size{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 3`),
 				regexp.MustCompile(`pool_min_size{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 2`),
@@ -52,7 +59,9 @@ func TestPoolInfoCollector(t *testing.T) {
 				regexp.MustCompile(`pool_pgp_num{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 16384`),
 				regexp.MustCompile(`pool_quota_max_bytes{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 512`),
 				regexp.MustCompile(`pool_quota_max_objects{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 1024`),
+				regexp.MustCompile(`pool_quota_enabled{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 1`),
 				regexp.MustCompile(`pool_stripe_width{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 4096`),
+				regexp.MustCompile(`pool_crush_rule{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 0`),
 				regexp.MustCompile(`pool_expansion_factor{cluster="ceph",pool="rbd",profile="replicated-ruleset",root="default"} 3`),
 			},
 			reUnmatch: []*regexp.Regexp{},
@@ -181,7 +190,9 @@ func TestPoolInfoCollector(t *testing.T) {
 				})
 			})).Return([]byte(""), "", fmt.Errorf("unknown erasure code profile"))
 
-			collector := NewPoolInfoCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+			conn.On("MgrCommand", mock.Anything).Return([]byte(`[]`), "", nil)
+
+			collector := NewPoolInfoCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), Version: Nautilus})
 
 			err := prometheus.Register(collector)
 			require.NoError(t, err)
@@ -206,3 +217,90 @@ func TestPoolInfoCollector(t *testing.T) {
 		}()
 	}
 }
+
+func TestPoolInfoCollectorRemovedPools(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd pool ls"
+	})).Return([]byte(`
+[
+	{"pool_id": 1, "pool_name": "rbd", "crush_rule": 0, "size": 3, "min_size": 2, "pg_num": 32, "pg_placement_num": 32, "type": 1},
+	{"pool_id": 3, "pool_name": "renamed", "crush_rule": 0, "size": 3, "min_size": 2, "pg_num": 32, "pg_placement_num": 32, "type": 1}
+]`,
+	), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd crush rule dump"
+	})).Return([]byte(`[]`), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd erasure-code-profile get"
+	})).Return([]byte(""), "", fmt.Errorf("unknown erasure code profile"))
+
+	conn.On("MgrCommand", mock.Anything).Return([]byte(`[]`), "", nil)
+
+	now := time.Now()
+	knownPools := newPoolSet()
+	knownPools.now = func() time.Time { return now }
+	knownPools.update(map[string]string{"1": "rbd", "2": "scratch", "3": "old"})
+
+	// every scraper sees the removed pool until the grace period is over,
+	// and the renamed one is not taken for removed
+	for _, tt := range []struct {
+		after    time.Duration
+		expected string
+	}{
+		{
+			after: 0,
+			expected: `
+# HELP ceph_pool_removing Whether the pool was removed within the last few minutes (1) or still exists (0)
+# TYPE ceph_pool_removing gauge
+ceph_pool_removing{cluster="ceph",pool="rbd"} 0
+ceph_pool_removing{cluster="ceph",pool="renamed"} 0
+ceph_pool_removing{cluster="ceph",pool="scratch"} 1
+`,
+		},
+		{
+			after: time.Minute,
+			expected: `
+# HELP ceph_pool_removing Whether the pool was removed within the last few minutes (1) or still exists (0)
+# TYPE ceph_pool_removing gauge
+ceph_pool_removing{cluster="ceph",pool="rbd"} 0
+ceph_pool_removing{cluster="ceph",pool="renamed"} 0
+ceph_pool_removing{cluster="ceph",pool="scratch"} 1
+`,
+		},
+		{
+			after: poolRemovedGrace + time.Minute,
+			expected: `
+# HELP ceph_pool_removing Whether the pool was removed within the last few minutes (1) or still exists (0)
+# TYPE ceph_pool_removing gauge
+ceph_pool_removing{cluster="ceph",pool="rbd"} 0
+ceph_pool_removing{cluster="ceph",pool="renamed"} 0
+`,
+		},
+	} {
+		now = now.Add(tt.after)
+		collector := NewPoolInfoCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), Version: Nautilus, knownPools: knownPools})
+
+		err := testutil.CollectAndCompare(collector, strings.NewReader(tt.expected), "ceph_pool_removing")
+		require.NoError(t, err)
+	}
+}
+
+func TestPoolInfoCollectorAutoscaleBeforeNautilus(t *testing.T) {
+	conn := &MockConn{}
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd pool ls"
+	})).Return([]byte(`[{"pool_name": "rbd", "crush_rule": 0, "size": 3, "min_size": 2, "pg_num": 32, "pg_placement_num": 32, "type": 1}]`), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd crush rule dump"
+	})).Return([]byte(`[]`), "", nil)
+	conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+		return monCommandPrefix(t, in) == "osd erasure-code-profile get"
+	})).Return([]byte(""), "", fmt.Errorf("unknown erasure code profile"))
+
+	luminous := &Version{Major: 12, Minor: 2, Patch: 13}
+	collector := NewPoolInfoCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), Version: luminous})
+
+	require.Equal(t, 0, testutil.CollectAndCount(collector, "ceph_pool_autoscale_pg_num"))
+	conn.AssertNotCalled(t, "MgrCommand", mock.Anything)
+}
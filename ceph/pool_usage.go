@@ -55,17 +55,43 @@ type PoolUsageCollector struct {
 	// UnfoundObjects shows the no. of RADOS unfound object within each pool.
 	UnfoundObjects *prometheus.Desc
 
-	// ReadIO tracks the read IO calls made for the images within each pool.
+	// ReadIO counts the read ops made on each pool. Like the other I/O
+	// counters, it is summed from the PG stats of the pool and can go down
+	// when PGs are split, merged or recreated, which rate() takes for a
+	// counter reset.
 	ReadIO *prometheus.Desc
 
-	// Readbytes tracks the read throughput made for the images within each pool.
+	// ReadBytes counts the bytes read from each pool.
 	ReadBytes *prometheus.Desc
 
-	// WriteIO tracks the write IO calls made for the images within each pool.
+	// WriteIO counts the write ops made on each pool.
 	WriteIO *prometheus.Desc
 
-	// WriteBytes tracks the write throughput made for the images within each pool.
+	// WriteBytes counts the bytes written to each pool.
 	WriteBytes *prometheus.Desc
+
+	// HitSetCount shows the no. of hit sets kept for a cache-tier pool.
+	HitSetCount *prometheus.Desc
+
+	// HitSetPeriod shows the time covered by each hit set of a cache-tier pool.
+	HitSetPeriod *prometheus.Desc
+
+	// PromoteOps tracks the rate of objects being promoted into a cache-tier pool.
+	PromoteOps *prometheus.Desc
+
+	// CompressBytesUsed shows the space taken by the compressed data of each pool.
+	CompressBytesUsed *prometheus.Desc
+
+	// CompressUnderBytes shows the size before compression of the compressed
+	// data of each pool.
+	CompressUnderBytes *prometheus.Desc
+
+	// CompressionRatio shows how much smaller the compressed data of each pool is.
+	CompressionRatio *prometheus.Desc
+
+	// CompressSavedBytes shows the space saved by compressing the data of
+	// each pool, 0 for pools without compressed data.
+	CompressSavedBytes *prometheus.Desc
 }
 
 // NewPoolUsageCollector creates a new instance of PoolUsageCollector and returns
@@ -78,43 +104,65 @@ func NewPoolUsageCollector(exporter *Exporter) *PoolUsageCollector {
 
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 
 	return &PoolUsageCollector{
 		conn:    exporter.Conn,
 		logger:  exporter.Logger,
 		version: exporter.Version,
 
-		UsedBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_used_bytes", cephNamespace, subSystem), "Capacity of the pool that is currently under use",
+		UsedBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_used_bytes", namespace, subSystem), "Capacity of the pool that is currently under use",
+			poolLabel, labels,
+		),
+		RawUsedBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_raw_used_bytes", namespace, subSystem), "Raw capacity of the pool that is currently under use, this factors in the size",
 			poolLabel, labels,
 		),
-		RawUsedBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_raw_used_bytes", cephNamespace, subSystem), "Raw capacity of the pool that is currently under use, this factors in the size",
+		MaxAvail: prometheus.NewDesc(fmt.Sprintf("%s_%s_available_bytes", namespace, subSystem), "Free space for the pool",
 			poolLabel, labels,
 		),
-		MaxAvail: prometheus.NewDesc(fmt.Sprintf("%s_%s_available_bytes", cephNamespace, subSystem), "Free space for the pool",
+		PercentUsed: prometheus.NewDesc(fmt.Sprintf("%s_%s_percent_used", namespace, subSystem), "Percentage of the capacity available to this pool that is used by this pool",
 			poolLabel, labels,
 		),
-		PercentUsed: prometheus.NewDesc(fmt.Sprintf("%s_%s_percent_used", cephNamespace, subSystem), "Percentage of the capacity available to this pool that is used by this pool",
+		Objects: prometheus.NewDesc(fmt.Sprintf("%s_%s_objects_total", namespace, subSystem), "Total no. of objects allocated within the pool",
 			poolLabel, labels,
 		),
-		Objects: prometheus.NewDesc(fmt.Sprintf("%s_%s_objects_total", cephNamespace, subSystem), "Total no. of objects allocated within the pool",
+		DirtyObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_dirty_objects_total", namespace, subSystem), "Total no. of dirty objects in a cache-tier pool",
 			poolLabel, labels,
 		),
-		DirtyObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_dirty_objects_total", cephNamespace, subSystem), "Total no. of dirty objects in a cache-tier pool",
+		UnfoundObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_unfound_objects_total", namespace, subSystem), "Total no. of unfound objects for the pool",
 			poolLabel, labels,
 		),
-		UnfoundObjects: prometheus.NewDesc(fmt.Sprintf("%s_%s_unfound_objects_total", cephNamespace, subSystem), "Total no. of unfound objects for the pool",
+		ReadIO: prometheus.NewDesc(fmt.Sprintf("%s_%s_read_total", namespace, subSystem), "Total read I/O calls for the pool",
 			poolLabel, labels,
 		),
-		ReadIO: prometheus.NewDesc(fmt.Sprintf("%s_%s_read_total", cephNamespace, subSystem), "Total read I/O calls for the pool",
+		ReadBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_read_bytes_total", namespace, subSystem), "Total read throughput for the pool",
 			poolLabel, labels,
 		),
-		ReadBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_read_bytes_total", cephNamespace, subSystem), "Total read throughput for the pool",
+		WriteIO: prometheus.NewDesc(fmt.Sprintf("%s_%s_write_total", namespace, subSystem), "Total write I/O calls for the pool",
 			poolLabel, labels,
 		),
-		WriteIO: prometheus.NewDesc(fmt.Sprintf("%s_%s_write_total", cephNamespace, subSystem), "Total write I/O calls for the pool",
+		WriteBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_write_bytes_total", namespace, subSystem), "Total write throughput for the pool",
 			poolLabel, labels,
 		),
-		WriteBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_write_bytes_total", cephNamespace, subSystem), "Total write throughput for the pool",
+		HitSetCount: prometheus.NewDesc(fmt.Sprintf("%s_%s_hit_set_count", namespace, subSystem), "No. of hit sets kept for a cache-tier pool",
+			poolLabel, labels,
+		),
+		HitSetPeriod: prometheus.NewDesc(fmt.Sprintf("%s_%s_hit_set_period_seconds", namespace, subSystem), "Time covered by each hit set of a cache-tier pool",
+			poolLabel, labels,
+		),
+		PromoteOps: prometheus.NewDesc(fmt.Sprintf("%s_%s_cache_promote_ops_per_sec", namespace, subSystem), "Objects promoted per second into a cache-tier pool",
+			poolLabel, labels,
+		),
+		CompressBytesUsed: prometheus.NewDesc(fmt.Sprintf("%s_%s_compress_bytes_used", namespace, subSystem), "Space taken by the compressed data of the pool",
+			poolLabel, labels,
+		),
+		CompressUnderBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_compress_under_bytes", namespace, subSystem), "Size before compression of the compressed data of the pool",
+			poolLabel, labels,
+		),
+		CompressionRatio: prometheus.NewDesc(fmt.Sprintf("%s_%s_compression_ratio", namespace, subSystem), "Size before compression divided by the space taken by the compressed data of the pool",
+			poolLabel, labels,
+		),
+		CompressSavedBytes: prometheus.NewDesc(fmt.Sprintf("%s_%s_compress_saved_bytes", namespace, subSystem), "Space saved by compressing the data of the pool",
 			poolLabel, labels,
 		),
 	}
@@ -126,6 +174,7 @@ type cephPoolStats struct {
 		ID    int    `json:"id"`
 		Stats struct {
 			BytesUsed    float64 `json:"bytes_used"`
+			RawBytesUsed float64 `json:"raw_bytes_used"`
 			StoredRaw    float64 `json:"stored_raw"`
 			Stored       float64 `json:"stored"`
 			MaxAvail     float64 `json:"max_avail"`
@@ -136,10 +185,29 @@ type cephPoolStats struct {
 			ReadBytes    float64 `json:"rd_bytes"`
 			WriteIO      float64 `json:"wr"`
 			WriteBytes   float64 `json:"wr_bytes"`
+
+			// only reported by Ceph versions supporting inline compression
+			CompressBytesUsed  *float64 `json:"compress_bytes_used"`
+			CompressUnderBytes *float64 `json:"compress_under_bytes"`
 		} `json:"stats"`
 	} `json:"pools"`
 }
 
+type cephCacheTierPools []struct {
+	Name         string `json:"pool_name"`
+	TierOf       int64  `json:"tier_of"`
+	CacheMode    string `json:"cache_mode"`
+	HitSetCount  int64  `json:"hit_set_count"`
+	HitSetPeriod int64  `json:"hit_set_period"`
+}
+
+type cephPoolIORates []struct {
+	Name        string `json:"pool_name"`
+	CacheIORate struct {
+		PromoteOps float64 `json:"promote_op_per_sec"`
+	} `json:"cache_io_rate"`
+}
+
 func (p *PoolUsageCollector) collect(ch chan<- prometheus.Metric) error {
 	cmd := p.cephUsageCommand()
 	buf, _, err := p.conn.MonCommand(cmd)
@@ -157,16 +225,37 @@ func (p *PoolUsageCollector) collect(ch chan<- prometheus.Metric) error {
 	}
 
 	for _, pool := range stats.Pools {
-		ch <- prometheus.MustNewConstMetric(p.UsedBytes, prometheus.GaugeValue, pool.Stats.Stored, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.RawUsedBytes, prometheus.GaugeValue, math.Max(pool.Stats.StoredRaw, pool.Stats.BytesUsed), pool.Name)
+		used, rawUsed := pool.Stats.Stored, math.Max(pool.Stats.StoredRaw, pool.Stats.BytesUsed)
+
+		// before Nautilus, bytes_used was the size of the data stored in the
+		// pool and raw_bytes_used the raw capacity it takes; stored and
+		// stored_raw did not exist
+		if p.version != nil && !p.version.AtLeast(14, 2) {
+			used, rawUsed = pool.Stats.BytesUsed, pool.Stats.RawBytesUsed
+		}
+
+		ch <- prometheus.MustNewConstMetric(p.UsedBytes, prometheus.GaugeValue, used, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.RawUsedBytes, prometheus.GaugeValue, rawUsed, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.MaxAvail, prometheus.GaugeValue, pool.Stats.MaxAvail, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.PercentUsed, prometheus.GaugeValue, pool.Stats.PercentUsed, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.Objects, prometheus.GaugeValue, pool.Stats.Objects, pool.Name)
 		ch <- prometheus.MustNewConstMetric(p.DirtyObjects, prometheus.GaugeValue, pool.Stats.DirtyObjects, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.ReadIO, prometheus.GaugeValue, pool.Stats.ReadIO, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.ReadBytes, prometheus.GaugeValue, pool.Stats.ReadBytes, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.WriteIO, prometheus.GaugeValue, pool.Stats.WriteIO, pool.Name)
-		ch <- prometheus.MustNewConstMetric(p.WriteBytes, prometheus.GaugeValue, pool.Stats.WriteBytes, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.ReadIO, prometheus.CounterValue, pool.Stats.ReadIO, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.ReadBytes, prometheus.CounterValue, pool.Stats.ReadBytes, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.WriteIO, prometheus.CounterValue, pool.Stats.WriteIO, pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.WriteBytes, prometheus.CounterValue, pool.Stats.WriteBytes, pool.Name)
+
+		if pool.Stats.CompressBytesUsed != nil && pool.Stats.CompressUnderBytes != nil {
+			used, under := *pool.Stats.CompressBytesUsed, *pool.Stats.CompressUnderBytes
+			ch <- prometheus.MustNewConstMetric(p.CompressBytesUsed, prometheus.GaugeValue, used, pool.Name)
+			ch <- prometheus.MustNewConstMetric(p.CompressUnderBytes, prometheus.GaugeValue, under, pool.Name)
+			ch <- prometheus.MustNewConstMetric(p.CompressSavedBytes, prometheus.GaugeValue, math.Max(under-used, 0), pool.Name)
+
+			// pools without compressed data have no ratio
+			if used > 0 {
+				ch <- prometheus.MustNewConstMetric(p.CompressionRatio, prometheus.GaugeValue, under/used, pool.Name)
+			}
+		}
 
 		st, err := p.conn.GetPoolStats(pool.Name)
 		if err != nil {
@@ -183,6 +272,65 @@ func (p *PoolUsageCollector) collect(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
+// collectCacheTier reports hit set settings and promotions for cache-tier
+// pools only; clusters without cache tiering don't get these metrics.
+func (p *PoolUsageCollector) collectCacheTier(ch chan<- prometheus.Metric) error {
+	cmd := p.cephPoolDetailCommand()
+	buf, _, err := p.conn.MonCommand(cmd)
+	if err != nil {
+		p.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return err
+	}
+
+	pools := cephCacheTierPools{}
+	if err := json.Unmarshal(buf, &pools); err != nil {
+		return err
+	}
+
+	cachePools := make(map[string]bool)
+	for _, pool := range pools {
+		if pool.TierOf < 0 || pool.CacheMode == "none" {
+			continue
+		}
+
+		cachePools[pool.Name] = true
+		ch <- prometheus.MustNewConstMetric(p.HitSetCount, prometheus.GaugeValue, float64(pool.HitSetCount), pool.Name)
+		ch <- prometheus.MustNewConstMetric(p.HitSetPeriod, prometheus.GaugeValue, float64(pool.HitSetPeriod), pool.Name)
+	}
+
+	if len(cachePools) == 0 {
+		return nil
+	}
+
+	cmd = p.cephPoolIOCommand()
+	buf, _, err = p.conn.MonCommand(cmd)
+	if err != nil {
+		p.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return err
+	}
+
+	rates := cephPoolIORates{}
+	if err := json.Unmarshal(buf, &rates); err != nil {
+		return err
+	}
+
+	for _, pool := range rates {
+		if !cachePools[pool.Name] {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(p.PromoteOps, prometheus.GaugeValue, pool.CacheIORate.PromoteOps, pool.Name)
+	}
+
+	return nil
+}
+
 func (p *PoolUsageCollector) cephUsageCommand() []byte {
 	cmd, err := json.Marshal(map[string]interface{}{
 		"prefix": "df",
@@ -195,6 +343,29 @@ func (p *PoolUsageCollector) cephUsageCommand() []byte {
 	return cmd
 }
 
+func (p *PoolUsageCollector) cephPoolDetailCommand() []byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd pool ls",
+		"detail": "detail",
+		"format": "json",
+	})
+	if err != nil {
+		p.logger.WithError(err).Panic("error marshalling ceph osd pool ls")
+	}
+	return cmd
+}
+
+func (p *PoolUsageCollector) cephPoolIOCommand() []byte {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd pool stats",
+		"format": "json",
+	})
+	if err != nil {
+		p.logger.WithError(err).Panic("error marshalling ceph osd pool stats")
+	}
+	return cmd
+}
+
 // Describe fulfills the prometheus.Collector's interface and sends the descriptors
 // of pool's metrics to the given channel.
 func (p *PoolUsageCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -209,6 +380,13 @@ func (p *PoolUsageCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- p.ReadBytes
 	ch <- p.WriteIO
 	ch <- p.WriteBytes
+	ch <- p.HitSetCount
+	ch <- p.HitSetPeriod
+	ch <- p.PromoteOps
+	ch <- p.CompressBytesUsed
+	ch <- p.CompressUnderBytes
+	ch <- p.CompressionRatio
+	ch <- p.CompressSavedBytes
 }
 
 // Collect extracts the current values of all the metrics and sends them to the
@@ -219,4 +397,9 @@ func (p *PoolUsageCollector) Collect(ch chan<- prometheus.Metric) {
 		p.logger.WithError(err).Error("error collecting pool usage metrics")
 		return
 	}
+
+	p.logger.Debug("collecting pool cache tier metrics")
+	if err := p.collectCacheTier(ch); err != nil {
+		p.logger.WithError(err).Error("error collecting pool cache tier metrics")
+	}
 }
@@ -15,6 +15,7 @@
 package ceph
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -29,9 +30,21 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// monCommandPrefix returns the prefix of a mon command given to the mock.
+func monCommandPrefix(t *testing.T, in interface{}) string {
+	v := map[string]interface{}{}
+
+	err := json.Unmarshal(in.([]byte), &v)
+	require.NoError(t, err)
+
+	prefix, _ := v["prefix"].(string)
+	return prefix
+}
+
 func TestPoolUsageCollector(t *testing.T) {
 	for _, tt := range []struct {
 		input              string
+		version            *Version
 		reMatch, reUnmatch []*regexp.Regexp
 	}{
 		{
@@ -164,6 +177,8 @@ func TestPoolUsageCollector(t *testing.T) {
 				regexp.MustCompile(`ceph_pool_used_bytes{cluster="ceph",pool="cinder_sas"} 7.1525351713e\+10`),
 				regexp.MustCompile(`ceph_pool_write_bytes_total{cluster="ceph",pool="cinder_sas"} 2.72268791808e\+11`),
 				regexp.MustCompile(`ceph_pool_write_total{cluster="ceph",pool="cinder_sas"} 4.5792703e\+07`),
+				regexp.MustCompile(`# TYPE ceph_pool_read_total counter`),
+				regexp.MustCompile(`# TYPE ceph_pool_write_bytes_total counter`),
 				regexp.MustCompile(`ceph_pool_available_bytes{cluster="ceph",pool="cinder_ssd"} 1.86205372416e\+11`),
 				regexp.MustCompile(`ceph_pool_dirty_objects_total{cluster="ceph",pool="cinder_ssd"} 16461`),
 				regexp.MustCompile(`ceph_pool_objects_total{cluster="ceph",pool="cinder_ssd"} 16461`),
@@ -175,18 +190,69 @@ func TestPoolUsageCollector(t *testing.T) {
 				regexp.MustCompile(`ceph_pool_write_total{cluster="ceph",pool="cinder_ssd"} 26721`),
 			},
 		},
+		{
+			input: `
+{"pools": [
+	{"name": "rgw", "id": 11, "stats": {"stored": 20, "compress_bytes_used": 1024, "compress_under_bytes": 4096}},
+	{"name": "rbd", "id": 12, "stats": {"stored": 20, "compress_bytes_used": 0, "compress_under_bytes": 0}},
+	{"name": "old", "id": 13, "stats": {"stored": 20}}
+]}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_compress_bytes_used{cluster="ceph",pool="rgw"} 1024`),
+				regexp.MustCompile(`ceph_pool_compress_under_bytes{cluster="ceph",pool="rgw"} 4096`),
+				regexp.MustCompile(`ceph_pool_compression_ratio{cluster="ceph",pool="rgw"} 4`),
+				regexp.MustCompile(`ceph_pool_compress_bytes_used{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_compress_under_bytes{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_pool_compress_saved_bytes{cluster="ceph",pool="rgw"} 3072`),
+				regexp.MustCompile(`ceph_pool_compress_saved_bytes{cluster="ceph",pool="rbd"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_compression_ratio{cluster="ceph",pool="rbd"}`),
+				regexp.MustCompile(`ceph_pool_compress_[a-z_]+{cluster="ceph",pool="old"}`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"kb_used": 20, "bytes_used": 20480, "raw_bytes_used": 61440, "objects": 5, "rd": 4, "wr": 6}}
+]}`,
+			version: &Version{Major: 12, Minor: 2, Patch: 13},
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_used_bytes{cluster="ceph",pool="rbd"} 20480`),
+				regexp.MustCompile(`ceph_pool_raw_used_bytes{cluster="ceph",pool="rbd"} 61440`),
+			},
+		},
+		{
+			input: `
+{"pools": [
+	{"name": "rbd", "id": 11, "stats": {"stored": 20480, "stored_raw": 61440, "bytes_used": 61440, "objects": 5, "rd": 4, "wr": 6}}
+]}`,
+			version: Nautilus,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_used_bytes{cluster="ceph",pool="rbd"} 20480`),
+				regexp.MustCompile(`ceph_pool_raw_used_bytes{cluster="ceph",pool="rbd"} 61440`),
+			},
+		},
 	} {
 		func() {
 			conn := &MockConn{}
-			conn.On("MonCommand", mock.Anything).Return(
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "df"
+			})).Return(
 				[]byte(tt.input), "", nil,
 			)
 
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "osd pool ls"
+			})).Return(
+				[]byte(`[]`), "", nil,
+			)
+
 			conn.On("GetPoolStats", mock.Anything).Return(
 				nil, fmt.Errorf("not implemented"),
 			)
 
-			collector := NewPoolUsageCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+			collector := NewPoolUsageCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New(), Version: tt.version})
 			err := prometheus.Register(collector)
 			require.NoError(t, err)
 			defer prometheus.Unregister(collector)
@@ -210,3 +276,90 @@ func TestPoolUsageCollector(t *testing.T) {
 		}()
 	}
 }
+
+func TestPoolUsageCollectorCacheTier(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		poolDetail         string
+		poolStats          string
+		reMatch, reUnmatch []*regexp.Regexp
+	}{
+		{
+			name: "cache tier pool",
+			poolDetail: `
+[
+	{"pool_name": "cold", "pool": 1, "tier_of": -1, "cache_mode": "none", "hit_set_count": 0, "hit_set_period": 0},
+	{"pool_name": "hot", "pool": 2, "tier_of": 1, "cache_mode": "writeback", "hit_set_count": 12, "hit_set_period": 14400}
+]`,
+			poolStats: `
+[
+	{"pool_name": "cold", "pool_id": 1, "recovery": {}, "recovery_rate": {}, "client_io_rate": {}},
+	{"pool_name": "hot", "pool_id": 2, "recovery": {}, "recovery_rate": {}, "client_io_rate": {}, "cache_io_rate": {"flush_bytes_sec": 1024, "promote_op_per_sec": 7}}
+]`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`pool_hit_set_count{cluster="ceph",pool="hot"} 12`),
+				regexp.MustCompile(`pool_hit_set_period_seconds{cluster="ceph",pool="hot"} 14400`),
+				regexp.MustCompile(`pool_cache_promote_ops_per_sec{cluster="ceph",pool="hot"} 7`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`pool_hit_set_count{cluster="ceph",pool="cold"}`),
+				regexp.MustCompile(`pool_cache_promote_ops_per_sec{cluster="ceph",pool="cold"}`),
+			},
+		},
+		{
+			name: "no cache tier",
+			poolDetail: `
+[
+	{"pool_name": "rbd", "pool": 1, "tier_of": -1, "cache_mode": "none", "hit_set_count": 0, "hit_set_period": 0}
+]`,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`pool_hit_set_count`),
+				regexp.MustCompile(`pool_hit_set_period_seconds`),
+				regexp.MustCompile(`pool_cache_promote_ops_per_sec`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "df"
+			})).Return(
+				[]byte(`{"pools": []}`), "", nil,
+			)
+
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "osd pool ls"
+			})).Return(
+				[]byte(tt.poolDetail), "", nil,
+			)
+
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "osd pool stats"
+			})).Return(
+				[]byte(tt.poolStats), "", nil,
+			)
+
+			collector := NewPoolUsageCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf))
+			}
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
+		})
+	}
+}
@@ -15,8 +15,12 @@
 package ceph
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -38,36 +42,71 @@ const (
 
 type rbdMirrorPoolStatus struct {
 	Summary struct {
-		Health       string `json:"health"`
-		DaemonHealth string `json:"daemon_health"`
-		ImageHealth  string `json:"image_health"`
-		States       struct {
-		} `json:"states"`
+		Health       string         `json:"health"`
+		DaemonHealth string         `json:"daemon_health"`
+		ImageHealth  string         `json:"image_health"`
+		States       map[string]int `json:"states"`
 	} `json:"summary"`
+	Images []struct {
+		Name        string `json:"name"`
+		State       string `json:"state"`
+		Description string `json:"description"`
+	} `json:"images"`
+}
+
+type rbdMirrorPoolInfo struct {
+	Mode string `json:"mode"`
+}
+
+// rbdMirrorReplayStatus is the JSON embedded in the description of images
+// mirrored using snapshots, e.g. `replaying, {"local_snapshot_timestamp":...}`.
+type rbdMirrorReplayStatus struct {
+	LocalSnapshotTimestamp  int64 `json:"local_snapshot_timestamp"`
+	RemoteSnapshotTimestamp int64 `json:"remote_snapshot_timestamp"`
 }
 
 // RbdMirrorStatusCollector displays statistics about each pool in the Ceph cluster.
 type RbdMirrorStatusCollector struct {
+	ctx     context.Context
+	conn    Conn
 	config  string
 	user    string
+	pools   []string
 	logger  *logrus.Logger
 	version *Version
 
-	getRbdMirrorStatus func(config string, user string) ([]byte, error)
+	getRbdMirrorStatus func(ctx context.Context, config string, user string, pool string) ([]byte, error)
+	getRbdMirrorInfo   func(ctx context.Context, config string, user string, pool string) ([]byte, error)
 
 	// RbdMirrorStatus shows the overall health status of a rbd-mirror.
-	RbdMirrorStatus prometheus.Gauge
+	RbdMirrorStatus *prometheus.GaugeVec
 
 	// RbdMirrorDaemonStatus shows the health status of a rbd-mirror daemons.
-	RbdMirrorDaemonStatus prometheus.Gauge
+	RbdMirrorDaemonStatus *prometheus.GaugeVec
 
 	// RbdMirrorImageStatus shows the health status of rbd-mirror images.
-	RbdMirrorImageStatus prometheus.Gauge
+	RbdMirrorImageStatus *prometheus.GaugeVec
+
+	// RbdMirrorImages shows the number of mirrored images in each state.
+	RbdMirrorImages *prometheus.GaugeVec
+
+	// RbdMirrorImageReplayLag shows how far behind the primary the last
+	// snapshot replayed for an image is.
+	RbdMirrorImageReplayLag *prometheus.GaugeVec
 }
 
 // rbdMirrorStatus get the RBD Mirror Pool Status
-var rbdMirrorStatus = func(config string, user string) ([]byte, error) {
-	out, err := exec.Command(rbdPath, "-c", config, "--user", user, "mirror", "pool", "status", "--format", "json").Output()
+func rbdMirrorStatus(ctx context.Context, config string, user string, pool string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, rbdPath, "-c", config, "--user", user, "mirror", "pool", "status", pool, "--verbose", "--format", "json").Output()
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rbdMirrorInfo get the RBD Mirror Pool Info, which contains the mirroring mode
+func rbdMirrorInfo(ctx context.Context, config string, user string, pool string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, rbdPath, "-c", config, "--user", user, "mirror", "pool", "info", pool, "--format", "json").Output()
 	if err != nil {
 		return nil, err
 	}
@@ -78,57 +117,89 @@ var rbdMirrorStatus = func(config string, user string) ([]byte, error) {
 func NewRbdMirrorStatusCollector(exporter *Exporter) *RbdMirrorStatusCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
 
 	collector := &RbdMirrorStatusCollector{
+		ctx:     exporter.collectContext(),
+		conn:    exporter.Conn,
 		config:  exporter.Config,
 		user:    exporter.User,
+		pools:   exporter.RbdMirrorPools,
 		logger:  exporter.Logger,
 		version: exporter.Version,
 
 		getRbdMirrorStatus: rbdMirrorStatus,
+		getRbdMirrorInfo:   rbdMirrorInfo,
 
-		RbdMirrorStatus: prometheus.NewGauge(
+		RbdMirrorStatus: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "rbd_mirror_pool_status",
 				Help:        "Health status of rbd-mirror, can vary only between 3 states (err:2, warn:1, ok:0)",
 				ConstLabels: labels,
 			},
+			[]string{"pool"},
 		),
 
-		RbdMirrorDaemonStatus: prometheus.NewGauge(
+		RbdMirrorDaemonStatus: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "rbd_mirror_pool_daemon_status",
 				Help:        "Health status of rbd-mirror daemons, can vary only between 3 states (err:2, warn:1, ok:0)",
 				ConstLabels: labels,
 			},
+			[]string{"pool"},
 		),
 
-		RbdMirrorImageStatus: prometheus.NewGauge(
+		RbdMirrorImageStatus: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "rbd_mirror_pool_image_status",
 				Help:        "Health status of rbd-mirror images, can vary only between 3 states (err:2, warn:1, ok:0)",
 				ConstLabels: labels,
 			},
+			[]string{"pool"},
+		),
+
+		RbdMirrorImages: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rbd_mirror_pool_images",
+				Help:        "Number of mirrored images in the pool by replay state",
+				ConstLabels: labels,
+			},
+			[]string{"pool", "state"},
+		),
+
+		RbdMirrorImageReplayLag: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rbd_mirror_image_replay_lag_seconds",
+				Help:        "Time between the latest primary snapshot and the latest snapshot replayed for a snapshot-mirrored image",
+				ConstLabels: labels,
+			},
+			[]string{"pool", "image"},
 		),
 	}
 
 	return collector
 }
 
-func (c *RbdMirrorStatusCollector) metricsList() []prometheus.Metric {
+func (c *RbdMirrorStatusCollector) collectorList() []prometheus.Collector {
 
 	if c.version.IsAtLeast(Pacific) {
-		return []prometheus.Metric{
+		return []prometheus.Collector{
 			c.RbdMirrorStatus,
 			c.RbdMirrorDaemonStatus,
 			c.RbdMirrorImageStatus,
+			c.RbdMirrorImages,
+			c.RbdMirrorImageReplayLag,
 		}
 	} else {
-		return []prometheus.Metric{
+		return []prometheus.Collector{
 			c.RbdMirrorStatus,
+			c.RbdMirrorImages,
+			c.RbdMirrorImageReplayLag,
 		}
 	}
 }
@@ -147,32 +218,142 @@ func (c *RbdMirrorStatusCollector) mirrorStatusStringToInt(status string) float6
 	}
 }
 
-// Describe provides the metrics descriptions to Prometheus
-func (c *RbdMirrorStatusCollector) Describe(ch chan<- *prometheus.Desc) {
-	for _, metric := range c.metricsList() {
-		ch <- metric.Desc()
+// mirroredPools returns the pools configured for the cluster, or if there are
+// none, the rbd pools that have mirroring enabled.
+func (c *RbdMirrorStatusCollector) mirroredPools() ([]string, error) {
+	if len(c.pools) > 0 {
+		return c.pools, nil
+	}
+
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd pool ls",
+		"detail": "detail",
+		"format": "json",
+	})
+	if err != nil {
+		c.logger.WithError(err).Panic("error marshalling ceph osd pool ls")
+	}
+
+	buf, _, err := c.conn.MonCommand(cmd)
+	if err != nil {
+		c.logger.WithError(err).WithField(
+			"args", string(cmd),
+		).Error("error executing mon command")
+
+		return nil, err
+	}
+
+	var pools []struct {
+		Name                string                 `json:"pool_name"`
+		ApplicationMetadata map[string]interface{} `json:"application_metadata"`
+	}
+	if err := json.Unmarshal(buf, &pools); err != nil {
+		return nil, err
+	}
+
+	var mirrored []string
+	for _, pool := range pools {
+		if _, ok := pool.ApplicationMetadata["rbd"]; !ok {
+			continue
+		}
+
+		out, err := c.getRbdMirrorInfo(c.ctx, c.config, c.user, pool.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		var info rbdMirrorPoolInfo
+		if err := json.Unmarshal(out, &info); err != nil {
+			return nil, err
+		}
+
+		if info.Mode != "" && info.Mode != "disabled" {
+			mirrored = append(mirrored, pool.Name)
+		}
 	}
+
+	return mirrored, nil
 }
 
-// Collect sends all the collected metrics Prometheus.
-func (c *RbdMirrorStatusCollector) Collect(ch chan<- prometheus.Metric) {
-	status, err := rbdMirrorStatus(c.config, c.user)
+func (c *RbdMirrorStatusCollector) collectPool(pool string) error {
+	status, err := c.getRbdMirrorStatus(c.ctx, c.config, c.user, pool)
 	if err != nil {
-		c.logger.WithError(err).Error("failed to run 'rbd mirror pool status'")
+		return err
 	}
+
 	var rbdStatus rbdMirrorPoolStatus
 	if err = json.Unmarshal(status, &rbdStatus); err != nil {
-		c.logger.WithError(err).Error("failed to Unmarshal rbd mirror pool status output")
+		return err
 	}
 
-	c.RbdMirrorStatus.Set(c.mirrorStatusStringToInt(rbdStatus.Summary.Health))
+	c.RbdMirrorStatus.WithLabelValues(pool).Set(c.mirrorStatusStringToInt(rbdStatus.Summary.Health))
 
 	if c.version.IsAtLeast(Pacific) {
-		c.RbdMirrorDaemonStatus.Set(c.mirrorStatusStringToInt(rbdStatus.Summary.DaemonHealth))
-		c.RbdMirrorImageStatus.Set(c.mirrorStatusStringToInt(rbdStatus.Summary.ImageHealth))
+		c.RbdMirrorDaemonStatus.WithLabelValues(pool).Set(c.mirrorStatusStringToInt(rbdStatus.Summary.DaemonHealth))
+		c.RbdMirrorImageStatus.WithLabelValues(pool).Set(c.mirrorStatusStringToInt(rbdStatus.Summary.ImageHealth))
 	}
-	for _, metric := range c.metricsList() {
-		ch <- metric
+
+	for state, count := range rbdStatus.Summary.States {
+		c.RbdMirrorImages.WithLabelValues(pool, state).Set(float64(count))
 	}
 
+	for _, image := range rbdStatus.Images {
+		// only snapshot based mirroring reports timestamps, as JSON following
+		// the replay state
+		i := strings.Index(image.Description, "{")
+		if i < 0 {
+			continue
+		}
+
+		var replay rbdMirrorReplayStatus
+		if err := json.Unmarshal([]byte(image.Description[i:]), &replay); err != nil {
+			c.logger.WithError(err).WithField("image", image.Name).Debug("failed to parse rbd-mirror image description")
+			continue
+		}
+
+		if replay.RemoteSnapshotTimestamp == 0 || replay.LocalSnapshotTimestamp == 0 {
+			continue
+		}
+
+		lag := replay.RemoteSnapshotTimestamp - replay.LocalSnapshotTimestamp
+		if lag < 0 {
+			lag = 0
+		}
+		c.RbdMirrorImageReplayLag.WithLabelValues(pool, image.Name).Set(float64(lag))
+	}
+
+	return nil
+}
+
+// Describe provides the metrics descriptions to Prometheus
+func (c *RbdMirrorStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.collectorList() {
+		metric.Describe(ch)
+	}
+}
+
+// Collect sends all the collected metrics Prometheus.
+func (c *RbdMirrorStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	pools, err := c.mirroredPools()
+	if errors.Is(err, os.ErrNotExist) {
+		c.logger.WithError(err).WithField("path", rbdPath).Error("rbd CLI not found, skipping rbd-mirror metrics")
+		return
+	} else if err != nil {
+		c.logger.WithError(err).Error("failed to list pools with rbd mirroring enabled")
+		return
+	}
+
+	for _, pool := range pools {
+		err := c.collectPool(pool)
+		if errors.Is(err, os.ErrNotExist) {
+			c.logger.WithError(err).WithField("path", rbdPath).Error("rbd CLI not found, skipping rbd-mirror metrics")
+			return
+		} else if err != nil {
+			c.logger.WithError(err).WithField("pool", pool).Error("failed to collect 'rbd mirror pool status'")
+		}
+	}
+
+	for _, metric := range c.collectorList() {
+		metric.Collect(ch)
+	}
 }
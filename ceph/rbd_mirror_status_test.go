@@ -15,30 +15,30 @@
 package ceph
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"regexp"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-func setStatus(b []byte) {
-	rbdMirrorStatus = func(string, string) ([]byte, error) {
-		return b, nil
-	}
-}
-
 func TestRbdMirrorStatusCollector(t *testing.T) {
 
 	for _, tt := range []struct {
-		input   []byte
-		reMatch []*regexp.Regexp
+		input     []byte
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
 	}{
 		{
 			input: []byte(`
@@ -53,9 +53,10 @@ func TestRbdMirrorStatusCollector(t *testing.T) {
 				}
 			  }`),
 			reMatch: []*regexp.Regexp{
-				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph"} 1`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph"} 1`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph",pool="rbd"} 1`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph",pool="rbd"} 1`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_images{cluster="ceph",pool="rbd",state="unknown"} 1`),
 			},
 		},
 		{
@@ -69,9 +70,9 @@ func TestRbdMirrorStatusCollector(t *testing.T) {
 				}
 			  }`),
 			reMatch: []*regexp.Regexp{
-				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph"} 1`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph"} 1`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph",pool="rbd"} 1`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph",pool="rbd"} 1`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph",pool="rbd"} 0`),
 			},
 		},
 		{
@@ -85,9 +86,9 @@ func TestRbdMirrorStatusCollector(t *testing.T) {
 				}
 			  }`),
 			reMatch: []*regexp.Regexp{
-				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph"} 0`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph"} 0`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph",pool="rbd"} 0`),
 			},
 		},
 		{
@@ -101,18 +102,64 @@ func TestRbdMirrorStatusCollector(t *testing.T) {
 				}
 			  }`),
 			reMatch: []*regexp.Regexp{
-				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph"} 2`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph"} 0`),
-				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph"} 2`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph",pool="rbd"} 2`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_daemon_status{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_image_status{cluster="ceph",pool="rbd"} 2`),
+			},
+		},
+		{
+			input: []byte(`
+			{
+				"summary": {
+				  "health": "OK",
+				  "daemon_health": "OK",
+				  "image_health": "OK",
+				  "states": {
+					"replaying": 2,
+					"stopped": 1
+				  }
+				},
+				"images": [
+				  {
+					"name": "vm-disk-1",
+					"global_id": "2d6a3e5c-8f3b-4b8e-9a1f-2c6a0b7d6a11",
+					"state": "up+replaying",
+					"description": "replaying, {\"bytes_per_second\":0.0,\"bytes_per_snapshot\":0.0,\"local_snapshot_timestamp\":1662000000,\"remote_snapshot_timestamp\":1662000300,\"replay_state\":\"idle\"}",
+					"last_update": "2022-09-01 02:45:00"
+				  },
+				  {
+					"name": "vm-disk-2",
+					"global_id": "9b1f4c2e-0c5d-4b0a-8f2e-7d3c1a9e5b22",
+					"state": "up+replaying",
+					"description": "replaying, master_position=[object_number=3, tag_tid=1, entry_tid=3], mirror_position=[object_number=3, tag_tid=1, entry_tid=3], entries_behind_master=0",
+					"last_update": "2022-09-01 02:45:00"
+				  },
+				  {
+					"name": "vm-disk-3",
+					"global_id": "4e8d2a1b-6f7c-4d3e-b2a9-1c0f8e7d6c33",
+					"state": "up+stopped",
+					"description": "local image is primary",
+					"last_update": "2022-09-01 02:45:00"
+				  }
+				]
+			  }`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph",pool="rbd"} 0`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_images{cluster="ceph",pool="rbd",state="replaying"} 2`),
+				regexp.MustCompile(`ceph_rbd_mirror_pool_images{cluster="ceph",pool="rbd",state="stopped"} 1`),
+				regexp.MustCompile(`ceph_rbd_mirror_image_replay_lag_seconds{cluster="ceph",image="vm-disk-1",pool="rbd"} 300`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`image="vm-disk-2"`),
+				regexp.MustCompile(`image="vm-disk-3"`),
 			},
 		},
 	} {
 		func() {
-			collector := NewRbdMirrorStatusCollector(&Exporter{Cluster: "ceph", Version: Pacific, Logger: logrus.New()})
-
-			var rbdStatus rbdMirrorPoolStatus
-			setStatus(tt.input)
-			_ = json.Unmarshal([]byte(tt.input), &rbdStatus)
+			collector := NewRbdMirrorStatusCollector(&Exporter{Cluster: "ceph", Version: Pacific, RbdMirrorPools: []string{"rbd"}, Logger: logrus.New()})
+			collector.getRbdMirrorStatus = func(ctx context.Context, config string, user string, pool string) ([]byte, error) {
+				return tt.input, nil
+			}
 
 			err := prometheus.Register(collector)
 			require.NoError(t, err)
@@ -131,6 +178,98 @@ func TestRbdMirrorStatusCollector(t *testing.T) {
 			for _, re := range tt.reMatch {
 				require.True(t, re.Match(buf))
 			}
+
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
 		}()
 	}
 }
+
+func TestRbdMirrorStatusCollectorPoolDiscovery(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		modes     map[string]string
+		infoErr   error
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			name:  "only mirrored rbd pools",
+			modes: map[string]string{"rbd": "image", "volumes": "disabled"},
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rbd_mirror_pool_status{cluster="ceph",pool="rbd"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`pool="volumes"`),
+				regexp.MustCompile(`pool="cephfs_data"`),
+			},
+		},
+		{
+			name:    "rbd CLI missing",
+			infoErr: &os.PathError{Op: "fork/exec", Path: rbdPath, Err: os.ErrNotExist},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rbd_mirror`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				v := map[string]interface{}{}
+
+				err := json.Unmarshal(in.([]byte), &v)
+				require.NoError(t, err)
+
+				return cmp.Equal(v, map[string]interface{}{
+					"prefix": "osd pool ls",
+					"detail": "detail",
+					"format": "json",
+				})
+			})).Return([]byte(`
+[
+	{"pool_name": "rbd", "application_metadata": {"rbd": {}}},
+	{"pool_name": "volumes", "application_metadata": {"rbd": {}}},
+	{"pool_name": "cephfs_data", "application_metadata": {"cephfs": {"data": "cephfs"}}}
+]`,
+			), "", nil)
+
+			collector := NewRbdMirrorStatusCollector(&Exporter{Conn: conn, Cluster: "ceph", Version: Pacific, Logger: logrus.New()})
+			collector.getRbdMirrorInfo = func(ctx context.Context, config string, user string, pool string) ([]byte, error) {
+				if tt.infoErr != nil {
+					return nil, tt.infoErr
+				}
+				mode, ok := tt.modes[pool]
+				if !ok {
+					return nil, errors.New("unexpected pool " + pool)
+				}
+				return []byte(`{"mode": "` + mode + `", "site_name": "site-a", "peers": []}`), nil
+			}
+			collector.getRbdMirrorStatus = func(ctx context.Context, config string, user string, pool string) ([]byte, error) {
+				return []byte(`{"summary": {"health": "OK", "daemon_health": "OK", "image_health": "OK", "states": {}}}`), nil
+			}
+
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf))
+			}
+
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
+		})
+	}
+}
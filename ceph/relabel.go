@@ -0,0 +1,151 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RelabelDrop drops the series whose label value matches the rule.
+	RelabelDrop = "drop"
+
+	// RelabelReplace rewrites the label value matching the rule.
+	RelabelReplace = "replace"
+)
+
+// RelabelRule rewrites or drops metrics according to the value of one of
+// their labels before they are exported. Regex is expected to be anchored so
+// that it matches whole label values.
+type RelabelRule struct {
+	SourceLabel string
+	Regex       *regexp.Regexp
+	Action      string
+
+	// Replacement is the new label value for RelabelReplace, and may refer to
+	// capture groups of Regex, e.g. "$1".
+	Replacement string
+}
+
+// relabeledMetric is a metric whose label values are rewritten on Write.
+type relabeledMetric struct {
+	prometheus.Metric
+	labels []*dto.LabelPair
+}
+
+func (m *relabeledMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	out.Label = m.labels
+	return nil
+}
+
+// relabel applies the rules in order to the metric, and returns it along
+// with its labels once rewritten. It returns false if the metric has to be
+// dropped.
+func relabel(rules []RelabelRule, metric prometheus.Metric) (prometheus.Metric, []*dto.LabelPair, bool) {
+	out := &dto.Metric{}
+	if err := metric.Write(out); err != nil {
+		// leave it to the registry to report the broken metric
+		return metric, nil, true
+	}
+
+	// the label pairs may be shared with the metric itself, so they are
+	// copied before being rewritten
+	changed := false
+	labels := append([]*dto.LabelPair(nil), out.GetLabel()...)
+	for _, rule := range rules {
+		for i, label := range labels {
+			if label.GetName() != rule.SourceLabel {
+				continue
+			}
+
+			value := label.GetValue()
+			match := rule.Regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+
+			switch rule.Action {
+			case RelabelDrop:
+				return nil, nil, false
+			case RelabelReplace:
+				replaced := string(rule.Regex.ExpandString(nil, rule.Replacement, value, match))
+				labels[i] = &dto.LabelPair{Name: label.Name, Value: &replaced}
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return metric, labels, true
+	}
+
+	return &relabeledMetric{Metric: metric, labels: labels}, labels, true
+}
+
+// seriesKey identifies the series of a metric by its descriptor and labels.
+func seriesKey(metric prometheus.Metric, labels []*dto.LabelPair) string {
+	var key strings.Builder
+	key.WriteString(metric.Desc().String())
+	for _, label := range labels {
+		key.WriteByte(0xff)
+		key.WriteString(label.GetName())
+		key.WriteByte('=')
+		key.WriteString(label.GetValue())
+	}
+	return key.String()
+}
+
+// relabelMetrics forwards the metrics from in to out once the rules have been
+// applied to them, until in is closed. Replacing different label values with
+// the same one can leave several metrics with the same labels, which would
+// fail the whole scrape; only the first of them is kept, and the others are
+// dropped with a warning.
+func relabelMetrics(rules []RelabelRule, in <-chan prometheus.Metric, out chan<- prometheus.Metric, logger *logrus.Logger) {
+	seen := make(map[string]bool)
+	duplicates := make(map[string]int)
+
+	for metric := range in {
+		metric, labels, ok := relabel(rules, metric)
+		if !ok {
+			continue
+		}
+
+		if labels != nil {
+			key := seriesKey(metric, labels)
+			if seen[key] {
+				duplicates[metric.Desc().String()]++
+				continue
+			}
+			seen[key] = true
+		}
+
+		out <- metric
+	}
+
+	for desc, count := range duplicates {
+		logger.WithFields(logrus.Fields{
+			"metric":     desc,
+			"duplicates": count,
+		}).Warn("relabeling left several series with the same labels, dropping all but the first")
+	}
+}
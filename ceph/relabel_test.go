@@ -0,0 +1,148 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporterRelabel(t *testing.T) {
+	desc := prometheus.NewDesc("ceph_fake_bytes", "fake metric", []string{"pool", "host"}, prometheus.Labels{"cluster": "ceph"})
+
+	for _, tt := range []struct {
+		name     string
+		rules    []RelabelRule
+		expected []map[string]string
+	}{
+		{
+			name: "no rules",
+			expected: []map[string]string{
+				{"cluster": "ceph", "pool": "rbd", "host": "node1.example.com"},
+				{"cluster": "ceph", "pool": "scratch-1", "host": "node2.example.com"},
+			},
+		},
+		{
+			name: "drop by label value",
+			rules: []RelabelRule{
+				{SourceLabel: "pool", Regex: regexp.MustCompile(`^(?:scratch-.*)$`), Action: RelabelDrop},
+			},
+			expected: []map[string]string{
+				{"cluster": "ceph", "pool": "rbd", "host": "node1.example.com"},
+			},
+		},
+		{
+			name: "replace label value",
+			rules: []RelabelRule{
+				{SourceLabel: "host", Regex: regexp.MustCompile(`^(?:(.*)\.example\.com)$`), Action: RelabelReplace, Replacement: "$1"},
+				{SourceLabel: "pool", Regex: regexp.MustCompile(`^(?:scratch)$`), Action: RelabelDrop},
+			},
+			expected: []map[string]string{
+				{"cluster": "ceph", "pool": "rbd", "host": "node1"},
+				{"cluster": "ceph", "pool": "scratch-1", "host": "node2"},
+			},
+		},
+		{
+			name: "replace collapsing series",
+			rules: []RelabelRule{
+				{SourceLabel: "host", Regex: regexp.MustCompile(`^(?:.*)$`), Action: RelabelReplace, Replacement: "any"},
+				{SourceLabel: "pool", Regex: regexp.MustCompile(`^(?:.*)$`), Action: RelabelReplace, Replacement: "all"},
+			},
+			expected: []map[string]string{
+				{"cluster": "ceph", "pool": "all", "host": "any"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// replaying a background refresh keeps Collect from querying the cluster
+			exporter := &Exporter{Cluster: "ceph", RefreshInterval: time.Minute, RelabelRules: tt.rules, Logger: logrus.New()}
+			exporter.cachedMetrics = []prometheus.Metric{
+				prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "rbd", "node1.example.com"),
+				prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 2, "scratch-1", "node2.example.com"),
+			}
+
+			// scrape twice to make sure the cached metrics aren't rewritten
+			ch := make(chan prometheus.Metric, 6)
+			exporter.Collect(ch)
+			exporter.Collect(ch)
+			close(ch)
+
+			labels := []map[string]string{}
+			for metric := range ch {
+				if metric.Desc() != desc {
+					continue
+				}
+
+				out := &dto.Metric{}
+				require.NoError(t, metric.Write(out))
+
+				values := make(map[string]string)
+				for _, label := range out.GetLabel() {
+					values[label.GetName()] = label.GetValue()
+				}
+				labels = append(labels, values)
+			}
+
+			require.Equal(t, append(tt.expected, tt.expected...), labels)
+		})
+	}
+}
+
+// uncheckedCollector collects from an exporter without describing it, so that
+// it can be registered without querying the cluster.
+type uncheckedCollector struct {
+	exporter *Exporter
+}
+
+func (c uncheckedCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c uncheckedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.Collect(ch)
+}
+
+func TestExporterRelabelCollisionGathers(t *testing.T) {
+	desc := prometheus.NewDesc("ceph_fake_bytes", "fake metric", []string{"owner"}, prometheus.Labels{"cluster": "ceph"})
+
+	exporter := &Exporter{
+		Cluster:         "ceph",
+		RefreshInterval: time.Minute,
+		RelabelRules: []RelabelRule{
+			{SourceLabel: "owner", Regex: regexp.MustCompile(`^(?:.*)$`), Action: RelabelReplace, Replacement: "redacted"},
+		},
+		Logger: logrus.New(),
+	}
+	exporter.cachedMetrics = []prometheus.Metric{
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "alice"),
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 2, "bob"),
+	}
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(uncheckedCollector{exporter}))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() == "ceph_fake_bytes" {
+			require.Len(t, family.GetMetric(), 1)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetryConn wraps a Conn to retry the commands that fail with a transient
+// error, such as those returned while the monitors hold an election. Other
+// errors, and those without an error code, are returned as they are.
+type RetryConn struct {
+	conn    Conn
+	retries int
+	backoff time.Duration
+	budget  time.Duration
+	logger  *logrus.Logger
+}
+
+// *RetryConn must implement the Conn.
+var _ Conn = &RetryConn{}
+
+// NewRetryConn returns a RetryConn retrying the commands of conn up to retries
+// times. The wait before each retry starts at backoff and doubles every time.
+// No retry is attempted once it would end past budget from the start of the
+// command, where 0 means no limit.
+func NewRetryConn(conn Conn, retries int, backoff time.Duration, budget time.Duration, logger *logrus.Logger) *RetryConn {
+	return &RetryConn{
+		conn:    conn,
+		retries: retries,
+		backoff: backoff,
+		budget:  budget,
+		logger:  logger,
+	}
+}
+
+// transientErrors are the error codes worth retrying a command for.
+var transientErrors = map[syscall.Errno]bool{
+	syscall.EAGAIN:       true,
+	syscall.EINTR:        true,
+	syscall.ETIMEDOUT:    true,
+	syscall.ENOTCONN:     true,
+	syscall.ECONNREFUSED: true,
+	syscall.ECONNRESET:   true,
+}
+
+// isTransient returns whether err carries one of the transientErrors, as the
+// negative error code of a librados call.
+func isTransient(err error) bool {
+	var coded interface{ ErrorCode() int }
+	if !errors.As(err, &coded) {
+		return false
+	}
+
+	return transientErrors[syscall.Errno(-coded.ErrorCode())]
+}
+
+// retry runs command until it succeeds, fails with an error that isn't
+// transient, or runs out of retries or budget.
+func (c *RetryConn) retry(what string, command func() error) error {
+	start := time.Now()
+	wait := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := command()
+		if err == nil || attempt >= c.retries || !isTransient(err) {
+			return err
+		}
+
+		if c.budget > 0 && time.Since(start)+wait > c.budget {
+			return err
+		}
+
+		c.logger.WithError(err).WithFields(logrus.Fields{
+			"command": what,
+			"attempt": attempt + 1,
+			"wait":    wait,
+		}).Warn("transient error executing command, retrying")
+
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// MonCommand executes a monitor command, retrying it on transient errors.
+func (c *RetryConn) MonCommand(args []byte) (buffer []byte, info string, err error) {
+	err = c.retry(string(args), func() error {
+		buffer, info, err = c.conn.MonCommand(args)
+		return err
+	})
+
+	return buffer, info, err
+}
+
+// MgrCommand executes a manager command, retrying it on transient errors.
+func (c *RetryConn) MgrCommand(args [][]byte) (buffer []byte, info string, err error) {
+	what := ""
+	if len(args) > 0 {
+		what = string(args[0])
+	}
+
+	err = c.retry(what, func() error {
+		buffer, info, err = c.conn.MgrCommand(args)
+		return err
+	})
+
+	return buffer, info, err
+}
+
+// GetPoolStats returns the stats of the given pool, retrying on transient
+// errors.
+func (c *RetryConn) GetPoolStats(pool string) (stats *PoolStat, err error) {
+	err = c.retry("pool stats "+pool, func() error {
+		stats, err = c.conn.GetPoolStats(pool)
+		return err
+	})
+
+	return stats, err
+}
+
+// WithDaemonConn returns a Conn that runs the commands of c as c does, and
+// passes daemon commands through to daemons, so that collectors find it to
+// implement DaemonConn.
+func (c *RetryConn) WithDaemonConn(daemons DaemonConn) Conn {
+	return &daemonRetryConn{RetryConn: c, daemons: daemons}
+}
+
+// daemonRetryConn is a RetryConn that also sends commands to daemon admin
+// sockets. Those are local and are not retried.
+type daemonRetryConn struct {
+	*RetryConn
+	daemons DaemonConn
+}
+
+// *daemonRetryConn must implement the DaemonConn.
+var _ DaemonConn = &daemonRetryConn{}
+
+// DaemonCommand sends a command to the admin socket of the given daemon.
+func (c *daemonRetryConn) DaemonCommand(daemon string, args []byte) ([]byte, error) {
+	return c.daemons.DaemonCommand(daemon, args)
+}
@@ -0,0 +1,165 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// codedError mimics the errors of librados, which carry a negative errno.
+type codedError int
+
+func (e codedError) Error() string {
+	return fmt.Sprintf("ret=%d", int(e))
+}
+
+func (e codedError) ErrorCode() int {
+	return int(e)
+}
+
+func TestRetryConn(t *testing.T) {
+	timedOut := codedError(-int(syscall.ETIMEDOUT))
+	notFound := codedError(-int(syscall.ENOENT))
+
+	for _, tt := range []struct {
+		name    string
+		errs    []error
+		retries int
+		budget  time.Duration
+		calls   int
+		wantErr error
+	}{
+		{
+			name:    "success",
+			errs:    []error{nil},
+			retries: 2,
+			calls:   1,
+		},
+		{
+			name:    "transient error then success",
+			errs:    []error{timedOut, nil},
+			retries: 2,
+			calls:   2,
+		},
+		{
+			name:    "wrapped transient error then success",
+			errs:    []error{fmt.Errorf("error connecting to rados: %w", timedOut), nil},
+			retries: 2,
+			calls:   2,
+		},
+		{
+			name:    "retries exhausted",
+			errs:    []error{timedOut, timedOut, timedOut},
+			retries: 2,
+			calls:   3,
+			wantErr: timedOut,
+		},
+		{
+			name:    "error not worth retrying",
+			errs:    []error{notFound},
+			retries: 2,
+			calls:   1,
+			wantErr: notFound,
+		},
+		{
+			name:    "error without a code",
+			errs:    []error{errors.New("fake error")},
+			retries: 2,
+			calls:   1,
+			wantErr: errors.New("fake error"),
+		},
+		{
+			name:    "budget exhausted",
+			errs:    []error{timedOut},
+			retries: 2,
+			budget:  time.Millisecond,
+			calls:   1,
+			wantErr: timedOut,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			for _, err := range tt.errs {
+				var buf []byte
+				if err == nil {
+					buf = []byte(`{}`)
+				}
+				conn.On("MonCommand", mock.Anything).Return(buf, "", err).Once()
+			}
+
+			retryConn := NewRetryConn(conn, tt.retries, 10*time.Millisecond, tt.budget, logrus.New())
+
+			buf, _, err := retryConn.MonCommand([]byte(`{"prefix": "status"}`))
+			if tt.wantErr != nil {
+				require.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, []byte(`{}`), buf)
+			}
+			conn.AssertNumberOfCalls(t, "MonCommand", tt.calls)
+		})
+	}
+}
+
+func TestRetryConnBackoff(t *testing.T) {
+	timedOut := codedError(-int(syscall.ETIMEDOUT))
+
+	conn := &MockConn{}
+	conn.On("GetPoolStats", "rbd").Return(nil, timedOut).Twice()
+	conn.On("GetPoolStats", "rbd").Return(&PoolStat{ObjectsUnfound: 1}, nil).Once()
+
+	retryConn := NewRetryConn(conn, 2, 20*time.Millisecond, 0, logrus.New())
+
+	// the waits double: 20ms, then 40ms
+	start := time.Now()
+	stats, err := retryConn.GetPoolStats("rbd")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), stats.ObjectsUnfound)
+	require.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+}
+
+// daemonConnFunc implements DaemonConn with a function.
+type daemonConnFunc func(daemon string, args []byte) ([]byte, error)
+
+func (f daemonConnFunc) DaemonCommand(daemon string, args []byte) ([]byte, error) {
+	return f(daemon, args)
+}
+
+func TestRetryConnWithDaemonConn(t *testing.T) {
+	retryConn := NewRetryConn(&MockConn{}, 2, 0, 0, logrus.New())
+
+	var conn Conn = retryConn
+	_, ok := conn.(DaemonConn)
+	require.False(t, ok, "daemon commands must be optional")
+
+	conn = retryConn.WithDaemonConn(daemonConnFunc(func(daemon string, args []byte) ([]byte, error) {
+		return []byte(daemon + " " + string(args)), nil
+	}))
+
+	daemons, ok := conn.(DaemonConn)
+	require.True(t, ok)
+
+	buf, err := daemons.DaemonCommand("osd.0", []byte(`{"prefix":"perf dump"}`))
+	require.NoError(t, err)
+	require.Equal(t, `osd.0 {"prefix":"perf dump"}`, string(buf))
+}
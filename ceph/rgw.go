@@ -15,9 +15,16 @@
 package ceph
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,9 +32,17 @@ import (
 )
 
 const rgwGCTimeFormat = "2006-01-02 15:04:05"
-const radosgwAdminPath = "/usr/bin/radosgw-admin"
+
+// DefaultRadosgwAdminPath is the location of the radosgw-admin binary used
+// when none has been configured.
+const DefaultRadosgwAdminPath = "/usr/bin/radosgw-admin"
+
 const backgroundCollectInterval = time.Duration(5 * time.Minute)
 
+// rgwUserConcurrency is the number of RGW users whose info and stats are read
+// at the same time.
+const rgwUserConcurrency = 8
+
 const (
 	RGWModeDisabled   = 0
 	RGWModeForeground = 1
@@ -57,26 +72,308 @@ func (gc rgwTaskGC) ExpiresAt() time.Time {
 }
 
 // rgwGetGCTaskList get the RGW Garbage Collection task list
-func rgwGetGCTaskList(config string, user string) ([]byte, error) {
+func rgwGetGCTaskList(ctx context.Context, radosgwAdmin string, config string, user string) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+
+	if out, err = exec.CommandContext(ctx, radosgwAdmin, "-c", config, "--user", user, "gc", "list", "--include-all").Output(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+type rgwUsageLog struct {
+	Entries []struct {
+		User    string `json:"user"`
+		Buckets []struct {
+			Bucket string `json:"bucket"`
+		} `json:"buckets"`
+	} `json:"entries"`
+}
+
+// rgwGetUsageLog get the contents of the RGW usage log
+func rgwGetUsageLog(ctx context.Context, radosgwAdmin string, config string, user string) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+
+	if out, err = exec.CommandContext(ctx, radosgwAdmin, "-c", config, "--user", user, "usage", "show", "--show-log-entries=true", "--show-log-sum=false").Output(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// rgwGetSyncStatus get the multisite sync status of the zone, which is only
+// available as text
+func rgwGetSyncStatus(ctx context.Context, radosgwAdmin string, config string, user string) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+
+	if out, err = exec.CommandContext(ctx, radosgwAdmin, "-c", config, "--user", user, "sync", "status").Output(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+var (
+	rgwSyncZonegroupRegex    = regexp.MustCompile(`^\s*zonegroup \S+ \((.*)\)`)
+	rgwSyncCurrentTimeRegex  = regexp.MustCompile(`^\s*current time (\S+)`)
+	rgwMetadataSyncLineRegex = regexp.MustCompile(`^\s*metadata sync`)
+	rgwDataSyncSourceRegex   = regexp.MustCompile(`^\s*(?:data sync )?source: \S+ \((.*)\)`)
+	rgwSyncCaughtUpRegex     = regexp.MustCompile(`is caught up with`)
+	rgwSyncBehindRegex       = regexp.MustCompile(`behind shards: \[([0-9,]*)\]`)
+	rgwSyncBehindCountRegex  = regexp.MustCompile(`is behind on (\d+) shards`)
+	rgwSyncRecoveringRegex   = regexp.MustCompile(`(\d+) shards are recovering`)
+	rgwSyncOldestRegex       = regexp.MustCompile(`oldest incremental change not applied: (\S+)`)
+)
+
+// rgwSyncTimeLayouts are the layouts of the times printed by
+// `radosgw-admin sync status`.
+var rgwSyncTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999-0700",
+}
+
+// rgwSyncState is the state of the metadata sync, or of the data sync from a
+// source zone.
+type rgwSyncState struct {
+	CaughtUp bool
+	// Behind is the number of shards that are behind, which are listed in
+	// BehindShards.
+	Behind           int
+	BehindShards     []string
+	RecoveringShards int
+	// OldestChange is the time of the oldest incremental change not applied
+	// yet, zero if there is none or its format is unknown.
+	OldestChange time.Time
+}
+
+// rgwSyncStatus is the multisite sync status of a zone.
+type rgwSyncStatus struct {
+	Zonegroup string
+	// CurrentTime is the time on the cluster, zero on releases that don't
+	// print it.
+	CurrentTime time.Time
+	// Metadata is nil on the metadata master zone, which doesn't sync it.
+	Metadata *rgwSyncState
+	// Data holds the data sync state by source zone.
+	Data map[string]*rgwSyncState
+}
+
+func parseRGWSyncTime(value string) (time.Time, bool) {
+	for _, layout := range rgwSyncTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseRGWSyncStatus parses the output of `radosgw-admin sync status`, e.g.
+//
+//	        realm 2d2b2b4e-... (gold)
+//	    zonegroup 9a6c2bd1-... (us)
+//	         zone 1e5b0c7a-... (us-west)
+//	 current time 2022-03-10T12:00:00Z
+//	metadata sync syncing
+//	              full sync: 0/64 shards
+//	              incremental sync: 64/64 shards
+//	              metadata is caught up with master
+//	    data sync source: 4d4d0a52-... (us-east)
+//	                      syncing
+//	                      full sync: 0/128 shards
+//	                      incremental sync: 128/128 shards
+//	                      data is behind on 2 shards
+//	                      behind shards: [17,94]
+//	                      oldest incremental change not applied: 2022-03-10T11:58:00.123456+0000 [17]
+//	                      1 shards are recovering
+//	                      recovering shards: [94]
+//	              source: 7f8e9d0c-... (us-central)
+//	                      ...
+func parseRGWSyncStatus(status string) (*rgwSyncStatus, error) {
+	syncStatus := &rgwSyncStatus{Data: make(map[string]*rgwSyncState)}
+
+	var state *rgwSyncState
+	for _, line := range strings.Split(status, "\n") {
+		if m := rgwSyncZonegroupRegex.FindStringSubmatch(line); m != nil {
+			syncStatus.Zonegroup = m[1]
+			continue
+		}
+
+		if m := rgwSyncCurrentTimeRegex.FindStringSubmatch(line); m != nil {
+			syncStatus.CurrentTime, _ = parseRGWSyncTime(m[1])
+			continue
+		}
+
+		if rgwMetadataSyncLineRegex.MatchString(line) {
+			state = nil
+			if !strings.Contains(line, "no sync") {
+				syncStatus.Metadata = &rgwSyncState{}
+				state = syncStatus.Metadata
+			}
+			continue
+		}
+
+		if m := rgwDataSyncSourceRegex.FindStringSubmatch(line); m != nil {
+			state = &rgwSyncState{}
+			syncStatus.Data[m[1]] = state
+			continue
+		}
+
+		if state == nil {
+			continue
+		}
+
+		if rgwSyncCaughtUpRegex.MatchString(line) {
+			state.CaughtUp = true
+		} else if m := rgwSyncBehindCountRegex.FindStringSubmatch(line); m != nil {
+			behind, err := strconv.Atoi(m[1])
+			if err != nil {
+				return nil, err
+			}
+			state.Behind = behind
+		} else if m := rgwSyncRecoveringRegex.FindStringSubmatch(line); m != nil {
+			recovering, err := strconv.Atoi(m[1])
+			if err != nil {
+				return nil, err
+			}
+			state.RecoveringShards = recovering
+		} else if m := rgwSyncOldestRegex.FindStringSubmatch(line); m != nil {
+			state.OldestChange, _ = parseRGWSyncTime(m[1])
+		} else if m := rgwSyncBehindRegex.FindStringSubmatch(line); m != nil && m[1] != "" {
+			for _, shard := range strings.Split(m[1], ",") {
+				if _, err := strconv.Atoi(shard); err != nil {
+					return nil, err
+				}
+				state.BehindShards = append(state.BehindShards, shard)
+			}
+		}
+	}
+
+	return syncStatus, nil
+}
+
+// rgwGetUserList get the IDs of all the RGW users, including their tenant
+func rgwGetUserList(ctx context.Context, radosgwAdmin string, config string, user string) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+
+	if out, err = exec.CommandContext(ctx, radosgwAdmin, "-c", config, "--user", user, "user", "list").Output(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+type rgwUserInfo struct {
+	UserID    string       `json:"user_id"`
+	Tenant    string       `json:"tenant"`
+	UserQuota rgwUserQuota `json:"user_quota"`
+}
+
+type rgwUserQuota struct {
+	Enabled    bool  `json:"enabled"`
+	MaxSize    int64 `json:"max_size"`
+	MaxObjects int64 `json:"max_objects"`
+}
+
+// rgwGetUserInfo get the details of an RGW user, including its quota
+func rgwGetUserInfo(ctx context.Context, radosgwAdmin string, config string, user string, uid string) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+
+	if out, err = exec.CommandContext(ctx, radosgwAdmin, "-c", config, "--user", user, "user", "info", "--uid", uid).Output(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+type rgwUserStats struct {
+	Stats struct {
+		Size       float64 `json:"size"`
+		NumObjects float64 `json:"num_objects"`
+	} `json:"stats"`
+}
+
+// rgwGetUserStats get the space and objects used by an RGW user
+func rgwGetUserStats(ctx context.Context, radosgwAdmin string, config string, user string, uid string) ([]byte, error) {
 	var (
 		out []byte
 		err error
 	)
 
-	if out, err = exec.Command(radosgwAdminPath, "-c", config, "--user", user, "gc", "list", "--include-all").Output(); err != nil {
+	if out, err = exec.CommandContext(ctx, radosgwAdmin, "-c", config, "--user", user, "user", "stats", "--uid", uid).Output(); err != nil {
 		return nil, err
 	}
 
 	return out, nil
 }
 
+// rgwLCStatuses are the states of the lifecycle processing of a bucket
+var rgwLCStatuses = []string{"UNINITIAL", "PROCESSING", "FAILED", "COMPLETE"}
+
+type rgwLCEntry struct {
+	Bucket string `json:"bucket"`
+	Status string `json:"status"`
+}
+
+// BucketAndTenant splits the lifecycle key of the entry, made of the tenant,
+// the bucket name and the bucket marker separated by colons.
+func (e rgwLCEntry) BucketAndTenant() (string, string) {
+	parts := strings.SplitN(e.Bucket, ":", 3)
+	if len(parts) != 3 {
+		return e.Bucket, ""
+	}
+	return parts[1], parts[0]
+}
+
+// rgwGetLCList get the lifecycle processing status of the buckets that have
+// a lifecycle configuration
+func rgwGetLCList(ctx context.Context, radosgwAdmin string, config string, user string) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+
+	if out, err = exec.CommandContext(ctx, radosgwAdmin, "-c", config, "--user", user, "lc", "list").Output(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RGWInstance is an RGW zone of a cluster to be collected with its own Ceph
+// user and config file.
+type RGWInstance struct {
+	Zone   string
+	User   string
+	Config string
+}
+
 // RGWCollector collects metrics from the RGW service
 type RGWCollector struct {
-	config     string
-	user       string
-	background bool
-	logger     *logrus.Logger
-	version    *Version
+	config       string
+	user         string
+	radosgwAdmin string
+	background   bool
+	userMetrics  bool
+	timeout      time.Duration
+	ctx          context.Context
+	logger       *logrus.Logger
+	version      *Version
 
 	// ActiveTasks reports the number of (expired) RGW GC tasks
 	ActiveTasks *prometheus.GaugeVec
@@ -88,25 +385,114 @@ type RGWCollector struct {
 	// PendingObjects reports the total number of RGW GC objects contained in pending tasks
 	PendingObjects *prometheus.GaugeVec
 
-	getRGWGCTaskList func(string, string) ([]byte, error)
+	// UsageLogEntries reports the number of entries held in the RGW usage log
+	UsageLogEntries *prometheus.GaugeVec
+
+	// SyncShardBehind reports the data log shards of a multisite source zone
+	// that this zone hasn't caught up with
+	SyncShardBehind *prometheus.GaugeVec
+
+	// DataSyncCaughtUp reports whether this zone has caught up with the
+	// data of a multisite source zone
+	DataSyncCaughtUp *prometheus.GaugeVec
+	// DataSyncBehindShards reports the number of data log shards of a
+	// source zone that this zone hasn't caught up with
+	DataSyncBehindShards *prometheus.GaugeVec
+	// DataSyncRecoveringShards reports the number of data log shards of a
+	// source zone that are recovering from sync errors
+	DataSyncRecoveringShards *prometheus.GaugeVec
+	// DataSyncOldestChangeAge reports how long ago the oldest data change
+	// of a source zone not applied yet was made; zones without pending
+	// changes are left out
+	DataSyncOldestChangeAge *prometheus.GaugeVec
+
+	// MetadataSyncCaughtUp reports whether this zone has caught up with the
+	// metadata of the master zone; the master zone itself is left out, as
+	// are the other metadata sync metrics
+	MetadataSyncCaughtUp *prometheus.GaugeVec
+	// MetadataSyncBehindShards reports the number of metadata log shards
+	// that this zone hasn't caught up with
+	MetadataSyncBehindShards *prometheus.GaugeVec
+	// MetadataSyncRecoveringShards reports the number of metadata log
+	// shards that are recovering from sync errors
+	MetadataSyncRecoveringShards *prometheus.GaugeVec
+	// MetadataSyncOldestChangeAge reports how long ago the oldest metadata
+	// change not applied yet was made
+	MetadataSyncOldestChangeAge *prometheus.GaugeVec
+
+	// UserQuotaMaxSize reports the quota on the space used by an RGW user;
+	// users without a size quota are left out
+	UserQuotaMaxSize *prometheus.GaugeVec
+
+	// UserQuotaMaxObjects reports the quota on the objects owned by an RGW
+	// user; users without an object quota are left out
+	UserQuotaMaxObjects *prometheus.GaugeVec
+
+	// UserUsedBytes reports the space used by an RGW user
+	UserUsedBytes *prometheus.GaugeVec
+
+	// UserObjects reports the number of objects owned by an RGW user
+	UserObjects *prometheus.GaugeVec
+
+	// LCBucketStatus reports the lifecycle processing status of a bucket;
+	// buckets without a lifecycle configuration are left out
+	LCBucketStatus *prometheus.GaugeVec
+
+	// LCBuckets reports the number of buckets in each lifecycle processing
+	// status
+	LCBuckets *prometheus.GaugeVec
+
+	getRGWGCTaskList func(context.Context, string, string, string) ([]byte, error)
+	getRGWUsageLog   func(context.Context, string, string, string) ([]byte, error)
+	getRGWSyncStatus func(context.Context, string, string, string) ([]byte, error)
+	getRGWUserList   func(context.Context, string, string, string) ([]byte, error)
+	getRGWUserInfo   func(context.Context, string, string, string, string) ([]byte, error)
+	getRGWUserStats  func(context.Context, string, string, string, string) ([]byte, error)
+	getRGWLCList     func(context.Context, string, string, string) ([]byte, error)
 }
 
 // NewRGWCollector creates an instance of the RGWCollector and instantiates
 // the individual metrics that we can collect from the RGW service
 func NewRGWCollector(exporter *Exporter, background bool) *RGWCollector {
+	return NewRGWInstanceCollector(exporter, RGWInstance{User: exporter.User, Config: exporter.Config}, background)
+}
+
+// NewRGWInstanceCollector creates an RGWCollector for the given RGW instance,
+// whose metrics are labelled with its zone if it has one.
+func NewRGWInstanceCollector(exporter *Exporter, instance RGWInstance, background bool) *RGWCollector {
 	labels := make(prometheus.Labels)
 	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+	if instance.Zone != "" {
+		labels["zone"] = instance.Zone
+	}
+
+	radosgwAdmin := exporter.RadosgwAdminPath
+	if radosgwAdmin == "" {
+		radosgwAdmin = DefaultRadosgwAdminPath
+	}
 
 	rgw := &RGWCollector{
-		config:           exporter.Config,
+		config:           instance.Config,
+		user:             instance.User,
+		radosgwAdmin:     radosgwAdmin,
 		background:       background,
+		userMetrics:      exporter.RGWUserMetrics,
+		timeout:          exporter.RGWTimeout,
+		ctx:              exporter.collectContext(),
 		logger:           exporter.Logger,
 		version:          exporter.Version,
 		getRGWGCTaskList: rgwGetGCTaskList,
+		getRGWUsageLog:   rgwGetUsageLog,
+		getRGWSyncStatus: rgwGetSyncStatus,
+		getRGWUserList:   rgwGetUserList,
+		getRGWUserInfo:   rgwGetUserInfo,
+		getRGWUserStats:  rgwGetUserStats,
+		getRGWLCList:     rgwGetLCList,
 
 		ActiveTasks: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "rgw_gc_active_tasks",
 				Help:        "RGW GC active task count",
 				ConstLabels: labels,
@@ -115,7 +501,7 @@ func NewRGWCollector(exporter *Exporter, background bool) *RGWCollector {
 		),
 		ActiveObjects: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "rgw_gc_active_objects",
 				Help:        "RGW GC active object count",
 				ConstLabels: labels,
@@ -124,7 +510,7 @@ func NewRGWCollector(exporter *Exporter, background bool) *RGWCollector {
 		),
 		PendingTasks: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "rgw_gc_pending_tasks",
 				Help:        "RGW GC pending task count",
 				ConstLabels: labels,
@@ -133,19 +519,157 @@ func NewRGWCollector(exporter *Exporter, background bool) *RGWCollector {
 		),
 		PendingObjects: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
-				Namespace:   cephNamespace,
+				Namespace:   namespace,
 				Name:        "rgw_gc_pending_objects",
 				Help:        "RGW GC pending object count",
 				ConstLabels: labels,
 			},
 			[]string{},
 		),
-	}
-
-	if rgw.background {
-		// rgw stats need to be collected in the background as this can take a while
-		// if we have a large backlog
-		go rgw.backgroundCollect()
+		UsageLogEntries: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_usage_log_entries",
+				Help:        "Number of entries in the RGW usage log",
+				ConstLabels: labels,
+			},
+			[]string{},
+		),
+		SyncShardBehind: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_sync_shard_behind",
+				Help:        "RGW multisite data log shard that is behind its source zone",
+				ConstLabels: labels,
+			},
+			[]string{"source_zone", "shard"},
+		),
+		DataSyncCaughtUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_data_sync_caught_up",
+				Help:        "Whether the RGW multisite data sync from the source zone is caught up",
+				ConstLabels: labels,
+			},
+			[]string{"source_zone", "zonegroup"},
+		),
+		DataSyncBehindShards: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_data_sync_behind_shards",
+				Help:        "Number of RGW multisite data log shards behind the source zone",
+				ConstLabels: labels,
+			},
+			[]string{"source_zone", "zonegroup"},
+		),
+		DataSyncRecoveringShards: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_data_sync_recovering_shards",
+				Help:        "Number of RGW multisite data log shards of the source zone recovering from sync errors",
+				ConstLabels: labels,
+			},
+			[]string{"source_zone", "zonegroup"},
+		),
+		DataSyncOldestChangeAge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_data_sync_oldest_change_age_seconds",
+				Help:        "Age of the oldest RGW multisite data change of the source zone not applied yet",
+				ConstLabels: labels,
+			},
+			[]string{"source_zone", "zonegroup"},
+		),
+		MetadataSyncCaughtUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_metadata_sync_caught_up",
+				Help:        "Whether the RGW multisite metadata sync from the master zone is caught up",
+				ConstLabels: labels,
+			},
+			[]string{"zonegroup"},
+		),
+		MetadataSyncBehindShards: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_metadata_sync_behind_shards",
+				Help:        "Number of RGW multisite metadata log shards behind the master zone",
+				ConstLabels: labels,
+			},
+			[]string{"zonegroup"},
+		),
+		MetadataSyncRecoveringShards: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_metadata_sync_recovering_shards",
+				Help:        "Number of RGW multisite metadata log shards recovering from sync errors",
+				ConstLabels: labels,
+			},
+			[]string{"zonegroup"},
+		),
+		MetadataSyncOldestChangeAge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_metadata_sync_oldest_change_age_seconds",
+				Help:        "Age of the oldest RGW multisite metadata change not applied yet",
+				ConstLabels: labels,
+			},
+			[]string{"zonegroup"},
+		),
+		UserQuotaMaxSize: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_user_quota_max_size_bytes",
+				Help:        "RGW user quota on the space used",
+				ConstLabels: labels,
+			},
+			[]string{"user", "tenant"},
+		),
+		UserQuotaMaxObjects: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_user_quota_max_objects",
+				Help:        "RGW user quota on the number of objects",
+				ConstLabels: labels,
+			},
+			[]string{"user", "tenant"},
+		),
+		UserUsedBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_user_used_bytes",
+				Help:        "Space used by the RGW user",
+				ConstLabels: labels,
+			},
+			[]string{"user", "tenant"},
+		),
+		UserObjects: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_user_objects",
+				Help:        "Number of objects owned by the RGW user",
+				ConstLabels: labels,
+			},
+			[]string{"user", "tenant"},
+		),
+		LCBucketStatus: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_lc_bucket_status",
+				Help:        "Lifecycle processing status of the RGW bucket, always 1",
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "tenant", "status"},
+		),
+		LCBuckets: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "rgw_lc_buckets",
+				Help:        "Number of RGW buckets in the lifecycle processing status",
+				ConstLabels: labels,
+			},
+			[]string{"status"},
+		),
 	}
 
 	return rgw
@@ -157,22 +681,323 @@ func (r *RGWCollector) collectorList() []prometheus.Collector {
 		r.ActiveObjects,
 		r.PendingTasks,
 		r.PendingObjects,
+		r.UsageLogEntries,
+		r.SyncShardBehind,
+		r.DataSyncCaughtUp,
+		r.DataSyncBehindShards,
+		r.DataSyncRecoveringShards,
+		r.DataSyncOldestChangeAge,
+		r.MetadataSyncCaughtUp,
+		r.MetadataSyncBehindShards,
+		r.MetadataSyncRecoveringShards,
+		r.MetadataSyncOldestChangeAge,
+		r.UserQuotaMaxSize,
+		r.UserQuotaMaxObjects,
+		r.UserUsedBytes,
+		r.UserObjects,
+		r.LCBucketStatus,
+		r.LCBuckets,
 	}
 }
 
-func (r *RGWCollector) backgroundCollect() error {
+// backgroundCollect collects every backgroundCollectInterval until stop is
+// closed, which also kills the radosgw-admin commands still running. RGW stats
+// are collected in the background as this can take a while if we have a
+// large backlog.
+func (r *RGWCollector) backgroundCollect(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	for {
-		r.logger.WithField("background", r.background).Debug("collecting RGW GC stats")
-		err := r.collect()
+		r.logger.WithField("background", r.background).Debug("collecting RGW stats")
+		err := r.collect(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.WithField("background", r.background).WithError(err).Error("error collecting RGW stats")
+		}
+
+		select {
+		case <-time.After(backgroundCollectInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *RGWCollector) collect(ctx context.Context) error {
+	gcErr := r.collectGC(ctx)
+	usageErr := r.collectUsageLog(ctx)
+	syncErr := r.collectSyncStatus(ctx)
+	lcErr := r.collectLC(ctx)
+
+	var userErr error
+	if r.userMetrics {
+		userErr = r.collectUsers(ctx)
+	}
+
+	for _, err := range []error{gcErr, usageErr, syncErr, lcErr} {
 		if err != nil {
-			r.logger.WithField("background", r.background).WithError(err).Error("error collecting RGW GC stats")
+			return err
+		}
+	}
+	return userErr
+}
+
+// runCommand runs a radosgw-admin command through get, killing it once it runs
+// for longer than the RGW timeout if one is set. Only the standard output of
+// the command is parsed; what a failed command wrote to its standard error is
+// logged at debug level.
+func (r *RGWCollector) runCommand(ctx context.Context, get func(context.Context) ([]byte, error)) ([]byte, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	out, err := get(ctx)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		r.logger.WithField("stderr", strings.TrimSpace(string(exitErr.Stderr))).Debug("radosgw-admin command failed")
+	}
+
+	if err != nil && r.timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("radosgw-admin killed after running for %s: %s", r.timeout, err)
+	}
+
+	return out, err
+}
+
+func (r *RGWCollector) collectLC(ctx context.Context) error {
+	data, err := r.runCommand(ctx, func(ctx context.Context) ([]byte, error) {
+		return r.getRGWLCList(ctx, r.radosgwAdmin, r.config, r.user)
+	})
+	if err != nil {
+		return err
+	}
+
+	entries := make([]rgwLCEntry, 0)
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return err
+	}
+
+	// buckets can be removed or lose their lifecycle configuration
+	r.LCBucketStatus.Reset()
+	r.LCBuckets.Reset()
+
+	buckets := make(map[string]float64, len(rgwLCStatuses))
+	for _, status := range rgwLCStatuses {
+		buckets[status] = 0
+	}
+	for _, entry := range entries {
+		bucket, tenant := entry.BucketAndTenant()
+		r.LCBucketStatus.WithLabelValues(bucket, tenant, entry.Status).Set(1)
+		buckets[entry.Status]++
+	}
+	for status, count := range buckets {
+		r.LCBuckets.WithLabelValues(status).Set(count)
+	}
+
+	return nil
+}
+
+func (r *RGWCollector) collectUsers(ctx context.Context) error {
+	data, err := r.runCommand(ctx, func(ctx context.Context) ([]byte, error) {
+		return r.getRGWUserList(ctx, r.radosgwAdmin, r.config, r.user)
+	})
+	if err != nil {
+		return err
+	}
+
+	uids := make([]string, 0)
+	err = json.Unmarshal(data, &uids)
+	if err != nil {
+		return err
+	}
+
+	// users can be removed
+	r.UserQuotaMaxSize.Reset()
+	r.UserQuotaMaxObjects.Reset()
+	r.UserUsedBytes.Reset()
+	r.UserObjects.Reset()
+
+	// a user whose info or stats can't be read, such as one removed since
+	// the list was taken, is skipped rather than losing every other user
+	sem := make(chan struct{}, rgwUserConcurrency)
+	var wg sync.WaitGroup
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(uid string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := r.collectUser(ctx, uid)
+			if err != nil && ctx.Err() == nil {
+				r.logger.WithError(err).WithField("uid", uid).Error("error collecting RGW user, skipping it")
+			}
+		}(uid)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (r *RGWCollector) collectUser(ctx context.Context, uid string) error {
+	data, err := r.runCommand(ctx, func(ctx context.Context) ([]byte, error) {
+		return r.getRGWUserInfo(ctx, r.radosgwAdmin, r.config, r.user, uid)
+	})
+	if err != nil {
+		return err
+	}
+
+	info := rgwUserInfo{}
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return err
+	}
+
+	data, err = r.runCommand(ctx, func(ctx context.Context) ([]byte, error) {
+		return r.getRGWUserStats(ctx, r.radosgwAdmin, r.config, r.user, uid)
+	})
+	if err != nil {
+		return err
+	}
+
+	stats := rgwUserStats{}
+	err = json.Unmarshal(data, &stats)
+	if err != nil {
+		return err
+	}
+
+	// IDs of users belonging to a tenant are listed as "tenant$user"
+	name := strings.TrimPrefix(uid, info.Tenant+"$")
+
+	// a negative limit means the quota is unlimited
+	quota := info.UserQuota
+	if quota.Enabled && quota.MaxSize >= 0 {
+		r.UserQuotaMaxSize.WithLabelValues(name, info.Tenant).Set(float64(quota.MaxSize))
+	}
+	if quota.Enabled && quota.MaxObjects >= 0 {
+		r.UserQuotaMaxObjects.WithLabelValues(name, info.Tenant).Set(float64(quota.MaxObjects))
+	}
+
+	r.UserUsedBytes.WithLabelValues(name, info.Tenant).Set(stats.Stats.Size)
+	r.UserObjects.WithLabelValues(name, info.Tenant).Set(stats.Stats.NumObjects)
+
+	return nil
+}
+
+func (r *RGWCollector) collectSyncStatus(ctx context.Context) error {
+	data, err := r.runCommand(ctx, func(ctx context.Context) ([]byte, error) {
+		return r.getRGWSyncStatus(ctx, r.radosgwAdmin, r.config, r.user)
+	})
+	if err != nil {
+		return err
+	}
+
+	status, err := parseRGWSyncStatus(string(data))
+	if err != nil {
+		return err
+	}
+
+	// age the pending changes by the cluster's clock where it is printed
+	now := status.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	// shards catch up, and zones can be removed from the zonegroup
+	for _, metric := range []*prometheus.GaugeVec{
+		r.SyncShardBehind,
+		r.DataSyncCaughtUp,
+		r.DataSyncBehindShards,
+		r.DataSyncRecoveringShards,
+		r.DataSyncOldestChangeAge,
+		r.MetadataSyncCaughtUp,
+		r.MetadataSyncBehindShards,
+		r.MetadataSyncRecoveringShards,
+		r.MetadataSyncOldestChangeAge,
+	} {
+		metric.Reset()
+	}
+
+	for zone, state := range status.Data {
+		for _, shard := range state.BehindShards {
+			r.SyncShardBehind.WithLabelValues(zone, shard).Set(1)
+		}
+
+		caughtUp := 0.0
+		if state.CaughtUp {
+			caughtUp = 1
+		}
+		r.DataSyncCaughtUp.WithLabelValues(zone, status.Zonegroup).Set(caughtUp)
+		r.DataSyncBehindShards.WithLabelValues(zone, status.Zonegroup).Set(float64(state.Behind))
+		r.DataSyncRecoveringShards.WithLabelValues(zone, status.Zonegroup).Set(float64(state.RecoveringShards))
+		if !state.OldestChange.IsZero() {
+			r.DataSyncOldestChangeAge.WithLabelValues(zone, status.Zonegroup).Set(math.Max(now.Sub(state.OldestChange).Seconds(), 0))
 		}
-		time.Sleep(backgroundCollectInterval)
 	}
+
+	if state := status.Metadata; state != nil {
+		caughtUp := 0.0
+		if state.CaughtUp {
+			caughtUp = 1
+		}
+		r.MetadataSyncCaughtUp.WithLabelValues(status.Zonegroup).Set(caughtUp)
+		r.MetadataSyncBehindShards.WithLabelValues(status.Zonegroup).Set(float64(state.Behind))
+		r.MetadataSyncRecoveringShards.WithLabelValues(status.Zonegroup).Set(float64(state.RecoveringShards))
+		if !state.OldestChange.IsZero() {
+			r.MetadataSyncOldestChangeAge.WithLabelValues(status.Zonegroup).Set(math.Max(now.Sub(state.OldestChange).Seconds(), 0))
+		}
+	}
+
+	return nil
+}
+
+func (r *RGWCollector) collectUsageLog(ctx context.Context) error {
+	data, err := r.runCommand(ctx, func(ctx context.Context) ([]byte, error) {
+		return r.getRGWUsageLog(ctx, r.radosgwAdmin, r.config, r.user)
+	})
+	if err != nil {
+		return err
+	}
+
+	usage := rgwUsageLog{}
+	err = json.Unmarshal(data, &usage)
+	if err != nil {
+		return err
+	}
+
+	// every bucket listed under a user is a separate usage log entry
+	entries := 0
+	for _, entry := range usage.Entries {
+		entries += len(entry.Buckets)
+	}
+
+	r.UsageLogEntries.WithLabelValues().Set(float64(entries))
+
+	return nil
 }
 
-func (r *RGWCollector) collect() error {
-	data, err := r.getRGWGCTaskList(r.config, r.user)
+func (r *RGWCollector) collectGC(ctx context.Context) error {
+	data, err := r.runCommand(ctx, func(ctx context.Context) ([]byte, error) {
+		return r.getRGWGCTaskList(ctx, r.radosgwAdmin, r.config, r.user)
+	})
 	if err != nil {
 		return err
 	}
@@ -221,10 +1046,10 @@ func (r *RGWCollector) Describe(ch chan<- *prometheus.Desc) {
 // It requires the caller to handle synchronization.
 func (r *RGWCollector) Collect(ch chan<- prometheus.Metric) {
 	if !r.background {
-		r.logger.WithField("background", r.background).Debug("collecting RGW GC stats")
-		err := r.collect()
+		r.logger.WithField("background", r.background).Debug("collecting RGW stats")
+		err := r.collect(r.ctx)
 		if err != nil {
-			r.logger.WithField("background", r.background).WithError(err).Error("error collecting RGW GC stats")
+			r.logger.WithField("background", r.background).WithError(err).Error("error collecting RGW stats")
 		}
 	}
 
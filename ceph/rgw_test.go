@@ -15,15 +15,21 @@
 package ceph
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
@@ -132,12 +138,291 @@ func TestRGWCollector(t *testing.T) {
 	} {
 		func() {
 			collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New()}, false) // run in foreground for testing
-			collector.getRGWGCTaskList = func(cluster string, user string) ([]byte, error) {
+			collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
 				if tt.input != nil {
 					return tt.input, nil
 				}
 				return nil, errors.New("fake error")
 			}
+			collector.getRGWUsageLog = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`{"entries": []}`), nil
+			}
+			collector.getRGWSyncStatus = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return nil, nil
+			}
+			collector.getRGWLCList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUserList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf))
+			}
+
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
+		}()
+	}
+}
+
+func TestRGWCollectorUsageLog(t *testing.T) {
+	for _, tt := range []struct {
+		input     []byte
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			input: []byte(`
+{
+    "entries": [
+        {
+            "user": "alice",
+            "buckets": [
+                {
+                    "bucket": "photos",
+                    "time": "2022-03-01 10:00:00.000000Z",
+                    "epoch": 1646128800,
+                    "owner": "alice",
+                    "categories": [
+                        {"category": "put_obj", "bytes_sent": 0, "bytes_received": 1024, "ops": 4, "successful_ops": 4}
+                    ]
+                },
+                {
+                    "bucket": "photos",
+                    "time": "2022-03-01 11:00:00.000000Z",
+                    "epoch": 1646132400,
+                    "owner": "alice",
+                    "categories": []
+                }
+            ]
+        },
+        {
+            "user": "bob",
+            "buckets": [
+                {
+                    "bucket": "backups",
+                    "time": "2022-03-01 10:00:00.000000Z",
+                    "epoch": 1646128800,
+                    "owner": "bob",
+                    "categories": []
+                }
+            ]
+        }
+    ]
+}`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_usage_log_entries{cluster="ceph"} 3`),
+			},
+		},
+		{
+			input: []byte(`{"entries": []}`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_usage_log_entries{cluster="ceph"} 0`),
+			},
+		},
+		{
+			// force an error return from getRGWUsageLog
+			input: nil,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_usage_log_entries`),
+			},
+		},
+	} {
+		func() {
+			collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New()}, false) // run in foreground for testing
+			collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUsageLog = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				if tt.input != nil {
+					return tt.input, nil
+				}
+				return nil, errors.New("fake error")
+			}
+			collector.getRGWSyncStatus = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return nil, nil
+			}
+			collector.getRGWLCList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUserList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf))
+			}
+
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
+		}()
+	}
+}
+
+func TestRGWCollectorSyncStatus(t *testing.T) {
+	for _, tt := range []struct {
+		input     []byte
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			input: []byte(`
+          realm 2d2b2b4e-5c6b-4b5a-9c3e-7e4e1f3b8a11 (gold)
+      zonegroup 9a6c2bd1-0e3c-4d8e-8f5e-4f6a1c2b3d44 (us)
+           zone 1e5b0c7a-8d2f-4a6b-9c1d-2f3e4a5b6c77 (us-west)
+  metadata sync syncing
+                full sync: 0/64 shards
+                incremental sync: 64/64 shards
+                metadata is behind on 1 shards
+                behind shards: [5]
+      data sync source: 4d4d0a52-1c2b-4e3f-8a9b-0c1d2e3f4a55 (us-east)
+                        syncing
+                        full sync: 0/128 shards
+                        incremental sync: 128/128 shards
+                        data is behind on 2 shards
+                        behind shards: [17,94]
+                source: 7f8e9d0c-1b2a-4c3d-8e4f-5a6b7c8d9e00 (us-central)
+                        syncing
+                        full sync: 0/128 shards
+                        incremental sync: 128/128 shards
+                        data is behind on 1 shards
+                        behind shards: [3]
+`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_sync_shard_behind{cluster="ceph",shard="17",source_zone="us-east"} 1`),
+				regexp.MustCompile(`ceph_rgw_sync_shard_behind{cluster="ceph",shard="94",source_zone="us-east"} 1`),
+				regexp.MustCompile(`ceph_rgw_sync_shard_behind{cluster="ceph",shard="3",source_zone="us-central"} 1`),
+				regexp.MustCompile(`ceph_rgw_data_sync_caught_up{cluster="ceph",source_zone="us-east",zonegroup="us"} 0`),
+				regexp.MustCompile(`ceph_rgw_metadata_sync_caught_up{cluster="ceph",zonegroup="us"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`shard="5"`),
+				regexp.MustCompile(`shard="3",source_zone="us-east"`),
+			},
+		},
+		{
+			input: []byte(`
+          realm 2d2b2b4e-5c6b-4b5a-9c3e-7e4e1f3b8a11 (gold)
+      zonegroup 9a6c2bd1-0e3c-4d8e-8f5e-4f6a1c2b3d44 (us)
+           zone 1e5b0c7a-8d2f-4a6b-9c1d-2f3e4a5b6c77 (us-west)
+   current time 2022-03-10T12:00:00Z
+zonegroup features enabled: resharding
+  metadata sync syncing
+                full sync: 0/64 shards
+                incremental sync: 64/64 shards
+                metadata is behind on 1 shards
+                behind shards: [5]
+                oldest incremental change not applied: 2022-03-10T11:59:30.000000+0000 [5]
+      data sync source: 4d4d0a52-1c2b-4e3f-8a9b-0c1d2e3f4a55 (us-east)
+                        syncing
+                        full sync: 0/128 shards
+                        incremental sync: 128/128 shards
+                        data is behind on 2 shards
+                        behind shards: [17,94]
+                        oldest incremental change not applied: 2022-03-10T11:58:00.500000+0000 [17]
+                        1 shards are recovering
+                        recovering shards: [94]
+                source: 7f8e9d0c-1b2a-4c3d-8e4f-5a6b7c8d9e00 (us-central)
+                        syncing
+                        full sync: 0/128 shards
+                        incremental sync: 128/128 shards
+                        data is caught up with source
+`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_data_sync_caught_up{cluster="ceph",source_zone="us-east",zonegroup="us"} 0`),
+				regexp.MustCompile(`ceph_rgw_data_sync_caught_up{cluster="ceph",source_zone="us-central",zonegroup="us"} 1`),
+				regexp.MustCompile(`ceph_rgw_data_sync_recovering_shards{cluster="ceph",source_zone="us-east",zonegroup="us"} 1`),
+				regexp.MustCompile(`ceph_rgw_data_sync_recovering_shards{cluster="ceph",source_zone="us-central",zonegroup="us"} 0`),
+				regexp.MustCompile(`ceph_rgw_data_sync_behind_shards{cluster="ceph",source_zone="us-east",zonegroup="us"} 2`),
+				regexp.MustCompile(`ceph_rgw_data_sync_behind_shards{cluster="ceph",source_zone="us-central",zonegroup="us"} 0`),
+				regexp.MustCompile(`ceph_rgw_metadata_sync_behind_shards{cluster="ceph",zonegroup="us"} 1`),
+				regexp.MustCompile(`ceph_rgw_data_sync_oldest_change_age_seconds{cluster="ceph",source_zone="us-east",zonegroup="us"} 119.5`),
+				regexp.MustCompile(`ceph_rgw_metadata_sync_caught_up{cluster="ceph",zonegroup="us"} 0`),
+				regexp.MustCompile(`ceph_rgw_metadata_sync_recovering_shards{cluster="ceph",zonegroup="us"} 0`),
+				regexp.MustCompile(`ceph_rgw_metadata_sync_oldest_change_age_seconds{cluster="ceph",zonegroup="us"} 30`),
+				regexp.MustCompile(`ceph_rgw_sync_shard_behind{cluster="ceph",shard="17",source_zone="us-east"} 1`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_data_sync_oldest_change_age_seconds{cluster="ceph",source_zone="us-central"`),
+				regexp.MustCompile(`shard="94",source_zone="us-central"`),
+			},
+		},
+		{
+			// single zone deployments have no data sync sources
+			input: []byte(`
+          realm  ()
+      zonegroup 9a6c2bd1-0e3c-4d8e-8f5e-4f6a1c2b3d44 (default)
+           zone 1e5b0c7a-8d2f-4a6b-9c1d-2f3e4a5b6c77 (default)
+  metadata sync no sync (zone is master)
+`),
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_sync_shard_behind{`),
+				regexp.MustCompile(`ceph_rgw_data_sync_caught_up{`),
+				regexp.MustCompile(`ceph_rgw_metadata_sync_caught_up{`),
+				regexp.MustCompile(`ceph_rgw_data_sync_behind_shards{`),
+				regexp.MustCompile(`ceph_rgw_metadata_sync_behind_shards{`),
+			},
+		},
+		{
+			// force an error return from getRGWSyncStatus
+			input: nil,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_sync_shard_behind{`),
+			},
+		},
+	} {
+		func() {
+			collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New()}, false) // run in foreground for testing
+			collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUsageLog = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`{"entries": []}`), nil
+			}
+			collector.getRGWSyncStatus = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				if tt.input != nil {
+					return tt.input, nil
+				}
+				return nil, errors.New("fake error")
+			}
+			collector.getRGWLCList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUserList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
 
 			err := prometheus.Register(collector)
 			require.NoError(t, err)
@@ -163,3 +448,371 @@ func TestRGWCollector(t *testing.T) {
 		}()
 	}
 }
+
+func TestRGWCollectorUsers(t *testing.T) {
+	userInfo := map[string]string{
+		"alice": `
+{
+    "user_id": "alice",
+    "display_name": "Alice",
+    "tenant": "",
+    "user_quota": {
+        "enabled": true,
+        "check_on_raw": false,
+        "max_size": 1099511627776,
+        "max_size_kb": 1073741824,
+        "max_objects": -1
+    }
+}`,
+		"acme$bob": `
+{
+    "user_id": "bob",
+    "display_name": "Bob",
+    "tenant": "acme",
+    "user_quota": {
+        "enabled": false,
+        "check_on_raw": false,
+        "max_size": 1024,
+        "max_size_kb": 1,
+        "max_objects": 10
+    }
+}`,
+		// cut short, as on a loaded cluster
+		"carol": `
+{
+    "user_id": "carol",
+    "display_na`,
+	}
+	userStats := map[string]string{
+		"alice":    `{"stats": {"size": 5368709120, "size_actual": 5368713216, "num_objects": 1200}}`,
+		"acme$bob": `{"stats": {"size": 0, "size_actual": 0, "num_objects": 0}}`,
+	}
+
+	for _, tt := range []struct {
+		input     []byte
+		disabled  bool
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			input: []byte(`["alice", "acme$bob"]`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_user_quota_max_size_bytes{cluster="ceph",tenant="",user="alice"} 1.099511627776e\+12`),
+				regexp.MustCompile(`ceph_rgw_user_used_bytes{cluster="ceph",tenant="",user="alice"} 5.36870912e\+09`),
+				regexp.MustCompile(`ceph_rgw_user_objects{cluster="ceph",tenant="",user="alice"} 1200`),
+				regexp.MustCompile(`ceph_rgw_user_used_bytes{cluster="ceph",tenant="acme",user="bob"} 0`),
+				regexp.MustCompile(`ceph_rgw_user_objects{cluster="ceph",tenant="acme",user="bob"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				// unlimited
+				regexp.MustCompile(`ceph_rgw_user_quota_max_objects{cluster="ceph",tenant="",user="alice"}`),
+				// disabled
+				regexp.MustCompile(`ceph_rgw_user_quota_max_size_bytes{cluster="ceph",tenant="acme",user="bob"}`),
+				regexp.MustCompile(`ceph_rgw_user_quota_max_objects{cluster="ceph",tenant="acme",user="bob"}`),
+			},
+		},
+		{
+			// carol is skipped, the others are still reported
+			input: []byte(`["alice", "carol", "acme$bob"]`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_user_used_bytes{cluster="ceph",tenant="",user="alice"} 5.36870912e\+09`),
+				regexp.MustCompile(`ceph_rgw_user_used_bytes{cluster="ceph",tenant="acme",user="bob"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`user="carol"`),
+			},
+		},
+		{
+			// not collected unless enabled
+			input:    []byte(`["alice", "acme$bob"]`),
+			disabled: true,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_user_`),
+			},
+		},
+		{
+			// force an error return from getRGWUserList
+			input: nil,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_user_`),
+			},
+		},
+	} {
+		func() {
+			collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New(), RGWUserMetrics: !tt.disabled}, false) // run in foreground for testing
+			collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUsageLog = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`{"entries": []}`), nil
+			}
+			collector.getRGWSyncStatus = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return nil, nil
+			}
+			collector.getRGWLCList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUserList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				if tt.input != nil {
+					return tt.input, nil
+				}
+				return nil, errors.New("fake error")
+			}
+			collector.getRGWUserInfo = func(ctx context.Context, radosgwAdmin string, cluster string, user string, uid string) ([]byte, error) {
+				return []byte(userInfo[uid]), nil
+			}
+			collector.getRGWUserStats = func(ctx context.Context, radosgwAdmin string, cluster string, user string, uid string) ([]byte, error) {
+				return []byte(userStats[uid]), nil
+			}
+
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf))
+			}
+
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
+		}()
+	}
+}
+
+func TestRGWCollectorLC(t *testing.T) {
+	for _, tt := range []struct {
+		input     []byte
+		reMatch   []*regexp.Regexp
+		reUnmatch []*regexp.Regexp
+	}{
+		{
+			input: []byte(`
+[
+    {
+        "bucket": ":photos:4d2a1c1e-8f1b-4b2a-9c3d-5e6f7a8b9c0d.4137.1",
+        "started": "Tue, 01 Mar 2022 00:00:05 GMT",
+        "status": "COMPLETE"
+    },
+    {
+        "bucket": "acme:logs:4d2a1c1e-8f1b-4b2a-9c3d-5e6f7a8b9c0d.4137.2",
+        "started": "Tue, 01 Mar 2022 00:00:07 GMT",
+        "status": "PROCESSING"
+    },
+    {
+        "bucket": ":backups:4d2a1c1e-8f1b-4b2a-9c3d-5e6f7a8b9c0d.4137.3",
+        "started": "Thu, 01 Jan 1970 00:00:00 GMT",
+        "status": "UNINITIAL"
+    }
+]`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_lc_bucket_status{bucket="photos",cluster="ceph",status="COMPLETE",tenant=""} 1`),
+				regexp.MustCompile(`ceph_rgw_lc_bucket_status{bucket="logs",cluster="ceph",status="PROCESSING",tenant="acme"} 1`),
+				regexp.MustCompile(`ceph_rgw_lc_bucket_status{bucket="backups",cluster="ceph",status="UNINITIAL",tenant=""} 1`),
+				regexp.MustCompile(`ceph_rgw_lc_buckets{cluster="ceph",status="COMPLETE"} 1`),
+				regexp.MustCompile(`ceph_rgw_lc_buckets{cluster="ceph",status="FAILED"} 0`),
+				regexp.MustCompile(`ceph_rgw_lc_buckets{cluster="ceph",status="PROCESSING"} 1`),
+				regexp.MustCompile(`ceph_rgw_lc_buckets{cluster="ceph",status="UNINITIAL"} 1`),
+			},
+		},
+		{
+			// no bucket has a lifecycle configuration
+			input: []byte(`[]`),
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_lc_buckets{cluster="ceph",status="COMPLETE"} 0`),
+			},
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_lc_bucket_status`),
+			},
+		},
+		{
+			// force an error return from getRGWLCList
+			input: nil,
+			reUnmatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_rgw_lc_`),
+			},
+		},
+	} {
+		func() {
+			collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New()}, false) // run in foreground for testing
+			collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+			collector.getRGWUsageLog = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`{"entries": []}`), nil
+			}
+			collector.getRGWSyncStatus = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return nil, nil
+			}
+			collector.getRGWLCList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				if tt.input != nil {
+					return tt.input, nil
+				}
+				return nil, errors.New("fake error")
+			}
+			collector.getRGWUserList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+				return []byte(`[]`), nil
+			}
+
+			err := prometheus.Register(collector)
+			require.NoError(t, err)
+			defer prometheus.Unregister(collector)
+
+			server := httptest.NewServer(promhttp.Handler())
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf))
+			}
+
+			for _, re := range tt.reUnmatch {
+				require.False(t, re.Match(buf))
+			}
+		}()
+	}
+}
+
+func TestRGWInstanceCollectorZoneLabel(t *testing.T) {
+	collector := NewRGWInstanceCollector(&Exporter{Cluster: "ceph", Logger: logrus.New()}, RGWInstance{Zone: "us-east", User: "rgw-east"}, false)
+	collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		require.Equal(t, "rgw-east", user)
+		return []byte(`[]`), nil
+	}
+	collector.getRGWUsageLog = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return []byte(`{"entries": []}`), nil
+	}
+	collector.getRGWSyncStatus = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return nil, nil
+	}
+	collector.getRGWLCList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return []byte(`[]`), nil
+	}
+	collector.getRGWUserList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return []byte(`[]`), nil
+	}
+
+	// the zone label isn't on the series registered by the other tests
+	registry := prometheus.NewRegistry()
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Regexp(t, `ceph_rgw_gc_active_tasks{cluster="ceph",zone="us-east"} 0`, string(buf))
+}
+
+func TestRGWCollectorCommandTimeout(t *testing.T) {
+	collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New(), RGWTimeout: 50 * time.Millisecond}, false)
+
+	// a hung radosgw-admin only returns once its context is done
+	collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	err := collector.collectGC(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "killed after running for 50ms")
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestRGWCollectorUsersConcurrency(t *testing.T) {
+	collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New(), RGWUserMetrics: true}, false)
+
+	uids := make([]string, 4*rgwUserConcurrency)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("user%d", i)
+	}
+	list, err := json.Marshal(uids)
+	require.NoError(t, err)
+
+	var running, maxRunning int64
+	collector.getRGWUserList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return list, nil
+	}
+	collector.getRGWUserInfo = func(ctx context.Context, radosgwAdmin string, cluster string, user string, uid string) ([]byte, error) {
+		n := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			max := atomic.LoadInt64(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt64(&maxRunning, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		return []byte(`{"user_id": "` + uid + `", "tenant": ""}`), nil
+	}
+	collector.getRGWUserStats = func(ctx context.Context, radosgwAdmin string, cluster string, user string, uid string) ([]byte, error) {
+		return []byte(`{"stats": {"size": 1, "num_objects": 1}}`), nil
+	}
+
+	require.NoError(t, collector.collectUsers(context.Background()))
+	require.Equal(t, len(uids), testutil.CollectAndCount(collector.UserUsedBytes))
+	require.Greater(t, maxRunning, int64(1))
+	require.LessOrEqual(t, maxRunning, int64(rgwUserConcurrency))
+}
+
+func TestRGWCollectorBackgroundStop(t *testing.T) {
+	collector := NewRGWCollector(&Exporter{Cluster: "ceph", Logger: logrus.New()}, true)
+
+	collected := make(chan struct{}, 1)
+	collector.getRGWGCTaskList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		select {
+		case collected <- struct{}{}:
+		default:
+		}
+		return []byte(`[]`), nil
+	}
+	collector.getRGWUsageLog = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return []byte(`{"entries": []}`), nil
+	}
+	collector.getRGWSyncStatus = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return nil, nil
+	}
+	collector.getRGWLCList = func(ctx context.Context, radosgwAdmin string, cluster string, user string) ([]byte, error) {
+		return []byte(`[]`), nil
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		collector.backgroundCollect(stop)
+		close(done)
+	}()
+
+	<-collected
+	close(stop)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("background collection did not stop")
+	}
+}
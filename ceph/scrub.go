@@ -0,0 +1,240 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// scrubStampLayouts are the layouts of the scrub stamps found in the PG dump,
+// which gained the T separator and the time zone with Octopus.
+var scrubStampLayouts = []string{
+	"2006-01-02T15:04:05.999999-0700",
+	"2006-01-02 15:04:05.999999",
+}
+
+// zeroScrubStamp is what Ceph reports for a PG that was never scrubbed.
+const zeroScrubStamp = "0.000000"
+
+// ScrubCollector reports for each pool how long ago its least recently
+// scrubbed PG was scrubbed and deep scrubbed, so that PGs left behind by the
+// scrub scheduler can be alerted on. It dumps the stats of every PG, which
+// is sizable on large clusters, and can be disabled on its own.
+type ScrubCollector struct {
+	conn    Conn
+	logger  *logrus.Logger
+	version *Version
+
+	// now returns the time the scrub ages are computed against.
+	now func() time.Time
+
+	// OldestScrubAge shows the time since the least recently scrubbed PG
+	// of a pool was scrubbed.
+	OldestScrubAge *prometheus.Desc
+
+	// OldestDeepScrubAge shows the time since the least recently deep
+	// scrubbed PG of a pool was deep scrubbed.
+	OldestDeepScrubAge *prometheus.Desc
+}
+
+// NewScrubCollector creates a new ScrubCollector instance.
+func NewScrubCollector(exporter *Exporter) *ScrubCollector {
+	labels := make(prometheus.Labels)
+	labels["cluster"] = exporter.Cluster
+	namespace := exporter.metricNamespace()
+
+	return &ScrubCollector{
+		conn:    exporter.Conn,
+		logger:  exporter.Logger,
+		version: exporter.Version,
+		now:     time.Now,
+
+		OldestScrubAge: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_oldest_scrub_age_seconds", namespace),
+			"Time since the least recently scrubbed PG of the pool was scrubbed",
+			[]string{"pool"},
+			labels,
+		),
+		OldestDeepScrubAge: prometheus.NewDesc(
+			fmt.Sprintf("%s_pool_oldest_deep_scrub_age_seconds", namespace),
+			"Time since the least recently deep scrubbed PG of the pool was deep scrubbed",
+			[]string{"pool"},
+			labels,
+		),
+	}
+}
+
+type cephPGScrubStat struct {
+	PGID               string `json:"pgid"`
+	LastScrubStamp     string `json:"last_scrub_stamp"`
+	LastDeepScrubStamp string `json:"last_deep_scrub_stamp"`
+}
+
+type cephPoolName struct {
+	ID   int64  `json:"poolnum"`
+	Name string `json:"poolname"`
+}
+
+// scrubAges holds the oldest scrub stamps of a pool.
+type scrubAges struct {
+	scrub, deepScrub time.Time
+}
+
+// parseScrubStamp parses a scrub stamp of the PG dump. A PG that was never
+// scrubbed is taken to have last been scrubbed at the Unix epoch.
+func parseScrubStamp(stamp string) (time.Time, error) {
+	if stamp == zeroScrubStamp {
+		return time.Unix(0, 0), nil
+	}
+	for _, layout := range scrubStampLayouts {
+		if t, err := time.Parse(layout, stamp); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown scrub stamp format %q", stamp)
+}
+
+func (c *ScrubCollector) getPoolNames() (map[int64]string, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "osd lspools",
+		"format": jsonFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := c.conn.MonCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var pools []cephPoolName
+	if err := json.Unmarshal(buf, &pools); err != nil {
+		return nil, err
+	}
+
+	names := make(map[int64]string, len(pools))
+	for _, pool := range pools {
+		names[pool.ID] = pool.Name
+	}
+	return names, nil
+}
+
+func (c *ScrubCollector) getPGScrubStats() ([]cephPGScrubStat, error) {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix":       "pg dump",
+		"dumpcontents": []string{"pgs"},
+		"format":       jsonFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := c.conn.MgrCommand([][]byte{cmd})
+	if err != nil {
+		return nil, err
+	}
+
+	// Nautilus dumps the bare list of PGs, later releases wrap it.
+	var stats []cephPGScrubStat
+	if buf = bytes.TrimSpace(buf); bytes.HasPrefix(buf, []byte("[")) {
+		err = json.Unmarshal(buf, &stats)
+	} else {
+		var dump struct {
+			PGStats []cephPGScrubStat `json:"pg_stats"`
+		}
+		err = json.Unmarshal(buf, &dump)
+		stats = dump.PGStats
+	}
+	return stats, err
+}
+
+func (c *ScrubCollector) collect(ch chan<- prometheus.Metric) error {
+	names, err := c.getPoolNames()
+	if err != nil {
+		return err
+	}
+
+	stats, err := c.getPGScrubStats()
+	if err != nil {
+		return err
+	}
+
+	oldest := make(map[string]*scrubAges)
+	for _, pg := range stats {
+		var poolID int64
+		if _, err := fmt.Sscanf(pg.PGID, "%d.", &poolID); err != nil {
+			return fmt.Errorf("invalid pgid %q: %s", pg.PGID, err)
+		}
+
+		name, ok := names[poolID]
+		if !ok {
+			continue
+		}
+
+		// a PG we can't make sense of shouldn't hide the other PGs
+		scrub, err := parseScrubStamp(pg.LastScrubStamp)
+		if err != nil {
+			c.logger.WithError(err).WithField("pgid", pg.PGID).Warn("skipping PG with invalid scrub stamp")
+			continue
+		}
+		deepScrub, err := parseScrubStamp(pg.LastDeepScrubStamp)
+		if err != nil {
+			c.logger.WithError(err).WithField("pgid", pg.PGID).Warn("skipping PG with invalid deep scrub stamp")
+			continue
+		}
+
+		ages, ok := oldest[name]
+		if !ok {
+			oldest[name] = &scrubAges{scrub: scrub, deepScrub: deepScrub}
+			continue
+		}
+		if scrub.Before(ages.scrub) {
+			ages.scrub = scrub
+		}
+		if deepScrub.Before(ages.deepScrub) {
+			ages.deepScrub = deepScrub
+		}
+	}
+
+	now := c.now()
+	for name, ages := range oldest {
+		ch <- prometheus.MustNewConstMetric(c.OldestScrubAge, prometheus.GaugeValue, now.Sub(ages.scrub).Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(c.OldestDeepScrubAge, prometheus.GaugeValue, now.Sub(ages.deepScrub).Seconds(), name)
+	}
+
+	return nil
+}
+
+// Describe sends the descriptors of the ScrubCollector metrics to the
+// provided channel.
+func (c *ScrubCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.OldestScrubAge
+	ch <- c.OldestDeepScrubAge
+}
+
+// Collect sends the oldest scrub ages of every pool to the provided channel.
+func (c *ScrubCollector) Collect(ch chan<- prometheus.Metric) {
+	c.logger.Debug("collecting scrub metrics")
+	if err := c.collect(ch); err != nil {
+		c.logger.WithError(err).Error("error collecting scrub metrics")
+	}
+}
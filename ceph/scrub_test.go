@@ -0,0 +1,104 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package ceph
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubCollector(t *testing.T) {
+	now := time.Date(2022, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name    string
+		input   string
+		reMatch []*regexp.Regexp
+	}{
+		{
+			name: "octopus",
+			input: `
+{
+	"pg_ready": true,
+	"pg_stats": [
+		{"pgid": "1.0", "last_scrub_stamp": "2022-03-10T11:00:00.000000+0000", "last_deep_scrub_stamp": "2022-03-09T12:00:00.000000+0000"},
+		{"pgid": "1.1", "last_scrub_stamp": "2022-03-10T10:00:00.123456+0000", "last_deep_scrub_stamp": "2022-03-03T12:00:00.000000+0000"},
+		{"pgid": "1.2", "last_scrub_stamp": "yesterday", "last_deep_scrub_stamp": "2022-01-01T00:00:00.000000+0000"},
+		{"pgid": "2.0", "last_scrub_stamp": "2022-03-10T11:59:00.000000+0000", "last_deep_scrub_stamp": "2022-03-10T11:59:00.000000+0000"},
+		{"pgid": "2.1", "last_scrub_stamp": "2022-03-10T11:59:00.000000+0000", "last_deep_scrub_stamp": "0.000000"},
+		{"pgid": "7.0", "last_scrub_stamp": "2022-03-01T00:00:00.000000+0000", "last_deep_scrub_stamp": "2022-03-01T00:00:00.000000+0000"}
+	]
+}`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_oldest_scrub_age_seconds{cluster="ceph",pool="rbd"} 7199.876544`),
+				regexp.MustCompile(`ceph_pool_oldest_deep_scrub_age_seconds{cluster="ceph",pool="rbd"} 604800`),
+				regexp.MustCompile(`ceph_pool_oldest_scrub_age_seconds{cluster="ceph",pool="cephfs_data"} 60`),
+				// a PG that was never deep scrubbed is as old as it gets
+				regexp.MustCompile(`ceph_pool_oldest_deep_scrub_age_seconds{cluster="ceph",pool="cephfs_data"} 1.6469136e\+09`),
+			},
+		},
+		{
+			name: "nautilus",
+			input: `
+[
+	{"pgid": "1.0", "last_scrub_stamp": "2022-03-10 11:00:00.000000", "last_deep_scrub_stamp": "2022-03-09 12:00:00.000000"}
+]`,
+			reMatch: []*regexp.Regexp{
+				regexp.MustCompile(`ceph_pool_oldest_scrub_age_seconds{cluster="ceph",pool="rbd"} 3600`),
+				regexp.MustCompile(`ceph_pool_oldest_deep_scrub_age_seconds{cluster="ceph",pool="rbd"} 86400`),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &MockConn{}
+			conn.On("MonCommand", mock.MatchedBy(func(in interface{}) bool {
+				return monCommandPrefix(t, in) == "osd lspools"
+			})).Return([]byte(`[{"poolnum": 1, "poolname": "rbd"}, {"poolnum": 2, "poolname": "cephfs_data"}]`), "", nil)
+			conn.On("MgrCommand", mock.Anything).Return([]byte(tt.input), "", nil)
+
+			collector := NewScrubCollector(&Exporter{Conn: conn, Cluster: "ceph", Logger: logrus.New()})
+			collector.now = func() time.Time { return now }
+
+			reg := prometheus.NewRegistry()
+			require.NoError(t, reg.Register(collector))
+
+			server := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, re := range tt.reMatch {
+				require.True(t, re.Match(buf), re.String())
+			}
+			// PGs of pools that no longer exist are ignored
+			require.NotContains(t, string(buf), `pool="7"`)
+		})
+	}
+}
@@ -46,8 +46,13 @@ var (
 )
 
 // IsAtLeast returns true if the version is at least as new as the given constraint
-// the commit is not considered
+// the commit is not considered. A nil version, one that could not be detected,
+// satisfies no constraint.
 func (version *Version) IsAtLeast(constraint *Version) bool {
+	if version == nil {
+		return false
+	}
+
 	if version.Major > constraint.Major {
 		return true
 	} else if version.Major < constraint.Major {
@@ -76,10 +81,16 @@ func (version *Version) IsAtLeast(constraint *Version) bool {
 	return true
 }
 
+// AtLeast returns true if the version is at least major.minor, for branching on
+// a release series without spelling out a whole *Version.
+func (version *Version) AtLeast(major, minor int) bool {
+	return version.IsAtLeast(&Version{Major: major, Minor: minor})
+}
+
 func (version *Version) String() string {
 	str := fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
 	if version.Revision != 0 || version.Commit != "" {
-		str = fmt.Sprintf("%s-%d", str, version.Patch)
+		str = fmt.Sprintf("%s-%d", str, version.Revision)
 		if version.Commit != "" {
 			str = fmt.Sprintf("%s-%s", str, version.Commit)
 		}
@@ -149,3 +149,52 @@ func TestVersion_IsAtLeast(t *testing.T) {
 		})
 	}
 }
+
+func TestVersion_String(t *testing.T) {
+	tests := []struct {
+		name    string
+		version *Version
+		want    string
+	}{
+		{
+			name:    "release",
+			version: &Version{Major: 16, Minor: 2, Patch: 7},
+			want:    "16.2.7",
+		},
+		{
+			name:    "revision and commit",
+			version: &Version{Major: 14, Minor: 2, Patch: 18, Revision: 97, Commit: "gcc1e126"},
+			want:    "14.2.18-97-gcc1e126",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.version.String(); got != tt.want {
+				t.Errorf("Version.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion_AtLeast(t *testing.T) {
+	tests := []struct {
+		name    string
+		version *Version
+		major   int
+		minor   int
+		want    bool
+	}{
+		{name: "same series", version: &Version{Major: 14, Minor: 2, Patch: 22}, major: 14, minor: 2, want: true},
+		{name: "newer major", version: Pacific, major: 14, minor: 2, want: true},
+		{name: "older minor", version: &Version{Major: 14, Minor: 1, Patch: 1}, major: 14, minor: 2, want: false},
+		{name: "older major", version: &Version{Major: 12, Minor: 2, Patch: 13}, major: 14, minor: 2, want: false},
+		{name: "unknown version", version: nil, major: 12, minor: 0, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.version.AtLeast(tt.major, tt.minor); got != tt.want {
+				t.Errorf("Version.AtLeast() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/digitalocean/ceph_exporter/ceph"
+)
+
+// exportedCluster is a cluster whose exporter is registered in a registry of
+// its own, so that it can be dropped without unregistering it.
+type exportedCluster struct {
+	config   ClusterConfig
+	conn     ceph.Conn
+	exporter *ceph.Exporter
+	registry *prometheus.Registry
+}
+
+// clusterSet holds the exported clusters by cluster label, and gathers their
+// metrics. The clusters are replaced when the exporter config is reloaded.
+type clusterSet struct {
+	mu       sync.Mutex
+	clusters map[string]*exportedCluster
+
+	// reloadMu serializes reloads, which create the new clusters without
+	// holding mu so that scrapes aren't held up by the monitors.
+	reloadMu sync.Mutex
+
+	// setDefaults fills in the settings a cluster leaves to the environment,
+	// and newCluster creates the connection and exporter of a cluster.
+	setDefaults func(*ClusterConfig)
+	newCluster  func(*ClusterConfig) (*exportedCluster, error)
+
+	logger *logrus.Logger
+}
+
+// clusterSet gathers the metrics of every cluster.
+var _ prometheus.Gatherer = &clusterSet{}
+
+// register creates the cluster and registers its exporter, labelled with the
+// extra labels of the cluster.
+func (s *clusterSet) register(cluster *ClusterConfig) (*exportedCluster, error) {
+	c, err := s.newCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	c.registry = prometheus.NewRegistry()
+	if err := prometheus.WrapRegistererWith(cluster.ExtraLabels, c.registry).Register(c.exporter); err != nil {
+		c.exporter.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// add starts exporting the cluster.
+func (s *clusterSet) add(cluster *ClusterConfig) error {
+	c, err := s.register(cluster)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.clusters == nil {
+		s.clusters = make(map[string]*exportedCluster)
+	}
+	s.clusters[cluster.ClusterLabel] = c
+
+	s.logger.WithField("cluster", cluster.ClusterLabel).Info("exporting cluster")
+	return nil
+}
+
+// conns returns the connections of the exported clusters by cluster label.
+func (s *clusterSet) conns() map[string]ceph.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conns := make(map[string]ceph.Conn, len(s.clusters))
+	for label, c := range s.clusters {
+		conns[label] = c.conn
+	}
+	return conns
+}
+
+// Gather collects the metrics of every exported cluster.
+func (s *clusterSet) Gather() ([]*dto.MetricFamily, error) {
+	s.mu.Lock()
+	gatherers := make(prometheus.Gatherers, 0, len(s.clusters))
+	for _, c := range s.clusters {
+		gatherers = append(gatherers, c.registry)
+	}
+	s.mu.Unlock()
+
+	return gatherers.Gather()
+}
+
+// gatherer returns the registry of the cluster with the given label, if it is
+// exported.
+func (s *clusterSet) gatherer(label string) (prometheus.Gatherer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.clusters[label]
+	if !ok {
+		return nil, false
+	}
+	return c.registry, true
+}
+
+// probeHandler serves the metrics of the single cluster named by the cluster
+// query parameter, so that each cluster can be scraped on its own.
+func probeHandler(clusters *clusterSet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		label := r.URL.Query().Get("cluster")
+		if label == "" {
+			http.Error(w, "cluster parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		registry, ok := clusters.gatherer(label)
+		if !ok {
+			http.Error(w, fmt.Sprintf("cluster %q is not exported", label), http.StatusNotFound)
+			return
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+// reload reads the exporter config at path again and exports the clusters
+// listed in it. Clusters whose settings are unchanged keep their exporter.
+// If the config is invalid or an exporter cannot be created, the clusters
+// exported before are left in place.
+func (s *clusterSet) reload(path string) error {
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		return err
+	}
+
+	for _, cluster := range cfg.Cluster {
+		s.setDefaults(cluster)
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	// registering a cluster queries its monitors, so the new clusters are
+	// created before taking mu, which is only held to swap them in
+	s.mu.Lock()
+	current := s.clusters
+	s.mu.Unlock()
+
+	next := make(map[string]*exportedCluster, len(cfg.Cluster))
+	var added []*exportedCluster
+	for _, cluster := range cfg.Cluster {
+		if c, ok := current[cluster.ClusterLabel]; ok && reflect.DeepEqual(c.config, *cluster) {
+			next[cluster.ClusterLabel] = c
+			continue
+		}
+
+		c, err := s.register(cluster)
+		if err != nil {
+			for _, c := range added {
+				c.exporter.Close()
+			}
+			return err
+		}
+		next[cluster.ClusterLabel] = c
+		added = append(added, c)
+	}
+
+	s.mu.Lock()
+	s.clusters = next
+	s.mu.Unlock()
+
+	for label, c := range current {
+		if next[label] == c {
+			continue
+		}
+
+		c.exporter.Close()
+		if _, ok := next[label]; !ok {
+			s.logger.WithField("cluster", label).Info("no longer exporting cluster")
+		}
+	}
+	for _, c := range added {
+		s.logger.WithField("cluster", c.config.ClusterLabel).Info("exporting cluster")
+	}
+
+	s.logger.WithField("file", path).Info("config reloaded")
+	return nil
+}
@@ -15,16 +15,82 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v2"
+
+	"github.com/digitalocean/ceph_exporter/ceph"
 )
 
 type ClusterConfig struct {
-	ClusterLabel string `yaml:"cluster_label"`
-	User         string `yaml:"user"`
-	ConfigFile   string `yaml:"config_file"`
+	ClusterLabel     string   `yaml:"cluster_label"`
+	User             string   `yaml:"user"`
+	ConfigFile       string   `yaml:"config_file"`
+	RadosgwAdminPath string   `yaml:"radosgw_admin_path"`
+	RgwMode          *int     `yaml:"rgw_mode"`
+	RbdMirrorPools   []string `yaml:"rbd_mirror_pools"`
+
+	// ClusterName is the name of the Ceph cluster, which unlike ClusterLabel
+	// names the admin sockets of its daemons, as in ceph-osd.0.asok.
+	ClusterName string `yaml:"cluster_name"`
+
+	// MetricNamespace overrides METRIC_NAMESPACE for the cluster.
+	MetricNamespace string `yaml:"metric_namespace"`
+
+	// Keyring and Key authenticate the user instead of the keyring named in
+	// ConfigFile. Key takes precedence over Keyring.
+	Keyring string `yaml:"keyring"`
+	Key     string `yaml:"key"`
+
+	// ExtraLabels are static labels added to every series of the cluster.
+	ExtraLabels map[string]string `yaml:"extra_labels"`
+
+	// RGW lists the RGW zones to collect separately, instead of using the
+	// cluster's own user and config file.
+	RGW []RGWConfig `yaml:"rgw"`
+
+	Relabel []RelabelConfig `yaml:"relabel"`
+}
+
+// RGWConfig describes an RGW zone of a cluster, with the Ceph user and
+// config file used to run radosgw-admin against it. Either defaults to the
+// one of the cluster.
+type RGWConfig struct {
+	Zone       string `yaml:"zone"`
+	User       string `yaml:"user"`
+	ConfigFile string `yaml:"config_file"`
+}
+
+// rgwInstances returns the RGW zones of the cluster, filling in the user and
+// config file of the cluster where they are not set.
+func (c *ClusterConfig) rgwInstances() []ceph.RGWInstance {
+	var instances []ceph.RGWInstance
+	for _, rgw := range c.RGW {
+		instance := ceph.RGWInstance{Zone: rgw.Zone, User: rgw.User, Config: rgw.ConfigFile}
+		if instance.User == "" {
+			instance.User = c.User
+		}
+		if instance.Config == "" {
+			instance.Config = c.ConfigFile
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// RelabelConfig describes a rule dropping or rewriting series by the value
+// of one of their labels.
+type RelabelConfig struct {
+	SourceLabel string `yaml:"source_label"`
+	Regex       string `yaml:"regex"`
+	Action      string `yaml:"action"`
+	Replacement string `yaml:"replacement"`
 }
 
 // Config is the top-level configuration for Metastord.
@@ -35,9 +101,95 @@ type Config struct {
 // fileExists returns true if the path exists and is a file.
 func fileExists(path string) bool {
 	stat, err := os.Stat(path)
-	return !os.IsNotExist(err) && !stat.IsDir()
+	return err == nil && !stat.IsDir()
 }
 
+// checkExecutable returns an error if the path does not exist or is not an
+// executable file.
+func checkExecutable(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if stat.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	if stat.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	}
+
+	return nil
+}
+
+// relabelRules compiles the relabel configuration of a cluster. Regexes are
+// anchored on both ends, so they have to match whole label values.
+func relabelRules(cfgs []RelabelConfig) ([]ceph.RelabelRule, error) {
+	var rules []ceph.RelabelRule
+	for _, cfg := range cfgs {
+		if cfg.SourceLabel == "" {
+			return nil, fmt.Errorf("relabel rule is missing source_label")
+		}
+
+		if cfg.Action != ceph.RelabelDrop && cfg.Action != ceph.RelabelReplace {
+			return nil, fmt.Errorf("unknown relabel action %q, must be %q or %q", cfg.Action, ceph.RelabelDrop, ceph.RelabelReplace)
+		}
+
+		re, err := regexp.Compile("^(?:" + cfg.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid relabel regex %q: %s", cfg.Regex, err)
+		}
+
+		rules = append(rules, ceph.RelabelRule{
+			SourceLabel: cfg.SourceLabel,
+			Regex:       re,
+			Action:      cfg.Action,
+			Replacement: cfg.Replacement,
+		})
+	}
+
+	return rules, nil
+}
+
+var labelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validateExtraLabels checks that the extra labels of a cluster are valid
+// label names which don't replace the cluster label.
+func validateExtraLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNameRegex.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid extra label name %q", name)
+		}
+
+		if name == "cluster" {
+			return fmt.Errorf("extra label %q is set by the exporter", name)
+		}
+	}
+
+	return nil
+}
+
+// validateNamespace checks that namespace is a valid prefix for metric names.
+func validateNamespace(namespace string) error {
+	if !labelNameRegex.MatchString(namespace) {
+		return fmt.Errorf("invalid metric namespace %q", namespace)
+	}
+
+	return nil
+}
+
+// ConfigError lists every problem found in the exporter config.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%d problem(s) in config: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// ParseConfig reads the exporter config from p, rejecting unknown keys, and
+// validates each cluster in it.
 func ParseConfig(p string) (*Config, error) {
 	cfgData, err := ioutil.ReadFile(p)
 	if err != nil {
@@ -45,10 +197,141 @@ func ParseConfig(p string) (*Config, error) {
 	}
 
 	var cfg Config
-	err = yaml.Unmarshal(cfgData, &cfg)
+	err = yaml.UnmarshalStrict(cfgData, &cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
+
+// validate checks the settings of every cluster, returning a *ConfigError
+// with all the problems found.
+func (cfg *Config) validate() error {
+	var problems []string
+
+	if len(cfg.Cluster) == 0 {
+		problems = append(problems, "no cluster configured")
+	}
+
+	seen := make(map[string]bool)
+	for i, cluster := range cfg.Cluster {
+		name := fmt.Sprintf("cluster[%d]", i)
+		if cluster.ClusterLabel != "" {
+			name = fmt.Sprintf("cluster[%d] %q", i, cluster.ClusterLabel)
+		}
+
+		problem := func(format string, args ...interface{}) {
+			problems = append(problems, name+": "+fmt.Sprintf(format, args...))
+		}
+
+		switch {
+		case cluster.ClusterLabel == "":
+			problem("cluster_label is required")
+		case seen[cluster.ClusterLabel]:
+			problem("cluster_label is used more than once")
+		}
+		seen[cluster.ClusterLabel] = true
+
+		if cluster.User == "" {
+			problem("user is required")
+		}
+
+		if cluster.ConfigFile == "" {
+			problem("config_file is required")
+		} else if !fileExists(cluster.ConfigFile) {
+			problem("config_file %s does not exist", cluster.ConfigFile)
+		}
+
+		if strings.Contains(cluster.ClusterName, "/") {
+			problem("cluster_name %q must not contain a slash", cluster.ClusterName)
+		}
+
+		if cluster.Keyring != "" && !fileExists(cluster.Keyring) {
+			problem("keyring %s does not exist", cluster.Keyring)
+		}
+
+		if cluster.RgwMode != nil {
+			switch *cluster.RgwMode {
+			case ceph.RGWModeDisabled, ceph.RGWModeForeground, ceph.RGWModeBackground:
+			default:
+				problem("rgw_mode %d is invalid, must be 0 (disabled), 1 (foreground) or 2 (background)", *cluster.RgwMode)
+			}
+		}
+
+		zones := make(map[string]bool)
+		for j, rgw := range cluster.RGW {
+			switch {
+			case rgw.Zone == "":
+				problem("rgw[%d]: zone is required", j)
+			case zones[rgw.Zone]:
+				problem("rgw[%d]: zone %q is listed more than once", j, rgw.Zone)
+			}
+			zones[rgw.Zone] = true
+
+			if rgw.ConfigFile != "" && !fileExists(rgw.ConfigFile) {
+				problem("rgw[%d]: config_file %s does not exist", j, rgw.ConfigFile)
+			}
+		}
+
+		if _, err := relabelRules(cluster.Relabel); err != nil {
+			problem("%s", err)
+		}
+
+		if err := validateExtraLabels(cluster.ExtraLabels); err != nil {
+			problem("%s", err)
+		}
+
+		if cluster.MetricNamespace != "" {
+			if err := validateNamespace(cluster.MetricNamespace); err != nil {
+				problem("%s", err)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	return nil
+}
+
+// printConfigError writes err to w, one problem per line for a *ConfigError.
+func printConfigError(w io.Writer, err error) {
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	for _, problem := range cfgErr.Problems {
+		fmt.Fprintln(w, problem)
+	}
+}
+
+// printConfigSummary writes the clusters that would be exported to w.
+func printConfigSummary(w io.Writer, cfg *Config) {
+	for _, cluster := range cfg.Cluster {
+		fmt.Fprintf(w, "cluster %q: user %q, config_file %s, rgw_mode %d", cluster.ClusterLabel, cluster.User, cluster.ConfigFile, *cluster.RgwMode)
+		switch {
+		case cluster.Key != "":
+			fmt.Fprint(w, ", key set")
+		case cluster.Keyring != "":
+			fmt.Fprintf(w, ", keyring %s", cluster.Keyring)
+		}
+		if cluster.MetricNamespace != "" && cluster.MetricNamespace != ceph.DefaultNamespace {
+			fmt.Fprintf(w, ", metric_namespace %s", cluster.MetricNamespace)
+		}
+		if len(cluster.RGW) > 0 {
+			fmt.Fprintf(w, ", %d RGW zone(s)", len(cluster.RGW))
+		}
+		if len(cluster.Relabel) > 0 {
+			fmt.Fprintf(w, ", %d relabel rule(s)", len(cluster.Relabel))
+		}
+		fmt.Fprintln(w)
+	}
+}
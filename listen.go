@@ -0,0 +1,73 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// unixSocketPrefix marks a listen address that is the path of a UNIX socket,
+// as in unix:/run/ceph_exporter.sock.
+const unixSocketPrefix = "unix:"
+
+// listen listens on addr, which is either a host:port or the path of a UNIX
+// socket prefixed with unixSocketPrefix. TCP connections are accepted through
+// emfileAwareTcpListener. Closing a UNIX socket listener removes the socket
+// file.
+func listen(addr string, logger *logrus.Logger) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixSocketPrefix) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return emfileAwareTcpListener{ln, logger}, nil
+	}
+
+	path := strings.TrimPrefix(addr, unixSocketPrefix)
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	return net.Listen("unix", path)
+}
+
+// removeStaleSocket removes the UNIX socket at path left behind by an exporter
+// that did not shut down cleanly. Sockets something still listens on, and
+// files that are not sockets, are left alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use", path)
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,106 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// acceptOne dials the address ln listens on and returns both ends of the
+// connection.
+func acceptOne(t *testing.T, ln net.Listener) (client net.Conn, server net.Conn) {
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.Dial(ln.Addr().Network(), ln.Addr().String())
+	require.NoError(t, err)
+
+	server = <-accepted
+	require.NotNil(t, server)
+
+	return client, server
+}
+
+func TestListenIPv6(t *testing.T) {
+	probe, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %s", err)
+	}
+	probe.Close()
+
+	ln, err := listen("[::1]:0", logrus.New())
+	require.NoError(t, err)
+	defer ln.Close()
+
+	require.IsType(t, emfileAwareTcpListener{}, ln)
+
+	addr, ok := ln.Addr().(*net.TCPAddr)
+	require.True(t, ok)
+	require.True(t, addr.IP.Equal(net.IPv6loopback), addr.String())
+
+	client, server := acceptOne(t, ln)
+	defer client.Close()
+	defer server.Close()
+
+	require.IsType(t, &net.TCPConn{}, server)
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ceph_exporter.sock")
+
+	// a socket left behind by an exporter that did not shut down cleanly
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listen(unixSocketPrefix+path, logrus.New())
+	require.NoError(t, err)
+
+	client, server := acceptOne(t, ln)
+	client.Close()
+	server.Close()
+
+	_, err = listen(unixSocketPrefix+path, logrus.New())
+	require.Error(t, err, "socket in use must not be removed")
+
+	require.NoError(t, ln.Close())
+	_, err = os.Lstat(path)
+	require.True(t, os.IsNotExist(err), "socket not removed on close")
+}
+
+func TestListenUnixNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ceph_exporter.sock")
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+
+	_, err := listen(unixSocketPrefix+path, logrus.New())
+	require.Error(t, err)
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+}
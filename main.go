@@ -16,41 +16,50 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ianschenck/envflag"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/version"
 	"github.com/sirupsen/logrus"
 
+	"github.com/digitalocean/ceph_exporter/asok"
 	"github.com/digitalocean/ceph_exporter/ceph"
 	"github.com/digitalocean/ceph_exporter/rados"
 )
 
 const (
 	defaultCephClusterLabel = "ceph"
+	defaultCephClusterName  = "ceph"
 	defaultCephConfigPath   = "/etc/ceph/ceph.conf"
 	defaultCephUser         = "admin"
 	defaultRadosOpTimeout   = 30 * time.Second
+	defaultCollectTimeout   = 60 * time.Second
 )
 
 // This horrible thing is a copy of tcpKeepAliveListener, tweaked to
 // specifically check if it hits EMFILE when doing an accept, and if so,
-// terminate the process.
+// terminate the process. It wraps any net.Listener, and only enables
+// keep-alives on the connections that are TCP.
 const keepAlive time.Duration = 3 * time.Minute
 
 type emfileAwareTcpListener struct {
-	*net.TCPListener
+	net.Listener
 	logger *logrus.Logger
 }
 
 func (ln emfileAwareTcpListener) Accept() (c net.Conn, err error) {
-	tc, err := ln.AcceptTCP()
+	c, err = ln.Listener.Accept()
 	if err != nil {
 		if oerr, ok := err.(*net.OpError); ok {
 			if serr, ok := oerr.Err.(*os.SyscallError); ok && serr.Err == syscall.EMFILE {
@@ -60,9 +69,31 @@ func (ln emfileAwareTcpListener) Accept() (c net.Conn, err error) {
 		// Default return
 		return
 	}
-	tc.SetKeepAlive(true)
-	tc.SetKeepAlivePeriod(keepAlive)
-	return tc, nil
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.SetKeepAlive(true)
+		tc.SetKeepAlivePeriod(keepAlive)
+	}
+	return c, nil
+}
+
+// newBuildInfoCollector reports the version, revision, branch and Go version
+// the exporter was built from, as set with -ldflags on the variables of
+// github.com/prometheus/common/version. Without them, the version and revision
+// are taken from the build information Go stamps the binary with.
+func newBuildInfoCollector(namespace string) prometheus.Collector {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version.Version == "" {
+			version.Version = info.Main.Version
+		}
+
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && version.Revision == "" {
+				version.Revision = setting.Value
+			}
+		}
+	}
+
+	return version.NewCollector(namespace + "_exporter")
 }
 
 // Verify that the exporter implements the interface correctly.
@@ -70,28 +101,72 @@ var _ prometheus.Collector = &ceph.Exporter{}
 
 func main() {
 	var (
-		metricsAddr    = envflag.String("TELEMETRY_ADDR", ":9128", "Host:Port for ceph_exporter's metrics endpoint")
+		metricsAddr    = envflag.String("TELEMETRY_ADDR", ":9128", "Host:Port, or unix:/path/to/socket, for ceph_exporter's metrics endpoint")
 		metricsPath    = envflag.String("TELEMETRY_PATH", "/metrics", "URL path for surfacing metrics to Prometheus")
+		adminAddr      = envflag.String("ADMIN_ADDR", "", "Host:Port for ceph_exporter's admin endpoint, best bound to localhost (disabled if empty)")
 		exporterConfig = envflag.String("EXPORTER_CONFIG", "/etc/ceph/exporter.yml", "Path to ceph_exporter config")
 		rgwMode        = envflag.Int("RGW_MODE", 0, "Enable collection of stats from RGW (0:disabled 1:enabled 2:background)")
+		rgwUserMetrics = envflag.Bool("RGW_USER_METRICS", false, "Collect the quota and usage of every RGW user, which runs two radosgw-admin commands per user")
 
-		logLevel = envflag.String("LOG_LEVEL", "info", "Logging level. One of: [trace, debug, info, warn, error, fatal, panic]")
+		disabledCollectors = envflag.String("DISABLED_COLLECTORS", "", "Comma separated list of collectors to disable, e.g. osd,pool_info")
+		radosgwAdminPath   = envflag.String("RADOSGW_ADMIN_PATH", ceph.DefaultRadosgwAdminPath, "Path to the radosgw-admin binary used for RGW collection")
+		metricNamespace    = envflag.String("METRIC_NAMESPACE", ceph.DefaultNamespace, "Prefix of the name of every exported metric")
+
+		logLevel  = envflag.String("LOG_LEVEL", "info", "Logging level. One of: [trace, debug, info, warn, error, fatal, panic]")
+		logFormat = envflag.String("LOG_FORMAT", "text", "Logging format. One of: [text, json]")
 
 		cephCluster        = envflag.String("CEPH_CLUSTER", defaultCephClusterLabel, "Ceph cluster name")
 		cephConfig         = envflag.String("CEPH_CONFIG", defaultCephConfigPath, "Path to Ceph config file")
 		cephUser           = envflag.String("CEPH_USER", defaultCephUser, "Ceph user to connect to cluster")
+		cephKeyring        = envflag.String("CEPH_KEYRING", "", "Path to the keyring of the Ceph user, overriding the one set in the Ceph config file")
+		cephKey            = envflag.String("CEPH_KEY", "", "Secret key of the Ceph user, taking precedence over any keyring")
+		adminSocketDir     = envflag.String("CEPH_ADMIN_SOCKET_DIR", "", "Directory of the admin sockets of the Ceph daemons on this host, e.g. "+asok.DefaultSocketDir+" (disabled if empty)")
 		cephRadosOpTimeout = envflag.Duration("CEPH_RADOS_OP_TIMEOUT", defaultRadosOpTimeout, "Ceph rados_osd_op_timeout and rados_mon_op_timeout used to contact cluster (0s means no limit)")
+		rgwOpTimeout       = envflag.Duration("RGW_OP_TIMEOUT", 0, "Time after which a radosgw-admin command is killed (0s uses CEPH_RADOS_OP_TIMEOUT)")
+		cephCmdRetries     = envflag.Int("CEPH_CMD_RETRIES", 2, "Times a Ceph command failing with a transient error is retried, within CEPH_RADOS_OP_TIMEOUT")
+		cephCmdBackoff     = envflag.Duration("CEPH_CMD_RETRY_BACKOFF", 500*time.Millisecond, "Wait before the first retry of a Ceph command, doubled for every further retry")
+		collectTimeout     = envflag.Duration("COLLECT_TIMEOUT", defaultCollectTimeout, "Total time budget for a single scrape, of which each collector may use what is left (0s means no limit)")
+		cacheTTL           = envflag.Duration("CACHE_TTL", 0, "Time for which collected metrics are cached and replayed instead of querying the cluster (0s disables caching)")
+		refreshInterval    = envflag.Duration("REFRESH_INTERVAL", 0, "Interval at which metrics are collected in the background and served on scrape (0s collects on every scrape)")
+		versionTTL         = envflag.Duration("VERSION_CACHE_TTL", 10*time.Minute, "Time for which the Ceph version of a cluster is cached before querying it again (0s queries on every collection)")
+		capacityWindow     = envflag.Duration("CAPACITY_WINDOW", 24*time.Hour, "Window over which the cluster fill rate is estimated to project when it will be full")
 
 		tlsCertPath = envflag.String("TLS_CERT_FILE_PATH", "", "Path to certificate file for TLS")
 		tlsKeyPath  = envflag.String("TLS_KEY_FILE_PATH", "", "Path to key file for TLS")
+
+		basicAuthUsername = envflag.String("BASIC_AUTH_USERNAME", "", "Username required to access the metrics and admin endpoints (basic auth is disabled if empty)")
+		basicAuthPassword = envflag.String("BASIC_AUTH_PASSWORD", "", "Password required to access the metrics and admin endpoints")
+
+		httpReadHeaderTimeout = envflag.Duration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second, "Time allowed to read the headers of a request (0s means no limit)")
+		httpReadTimeout       = envflag.Duration("HTTP_READ_TIMEOUT", 30*time.Second, "Time allowed to read a whole request (0s means no limit)")
+		httpWriteTimeout      = envflag.Duration("HTTP_WRITE_TIMEOUT", 2*time.Minute, "Time allowed to serve a request once its headers are read, which must cover a scrape (0s means no limit)")
+		httpIdleTimeout       = envflag.Duration("HTTP_IDLE_TIMEOUT", 2*time.Minute, "Time a keep-alive connection is kept open waiting for the next request (0s uses HTTP_READ_TIMEOUT)")
+
+		shutdownTimeout = envflag.Duration("SHUTDOWN_TIMEOUT", 30*time.Second, "Time given to scrapes in flight to finish when shutting down")
+		checkConfig     = envflag.Bool("CHECK_CONFIG", false, "Validate the cluster configuration, print the clusters that would be exported and exit")
+		readyTimeout    = envflag.Duration("READY_TIMEOUT", 5*time.Second, "Time within which the monitors of every cluster must answer for /ready to succeed")
 	)
 
 	envflag.Parse()
 
+	// RGW commands share the mon command timeout unless given their own
+	if *rgwOpTimeout == 0 {
+		*rgwOpTimeout = *cephRadosOpTimeout
+	}
+
 	logger := logrus.New()
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
+
+	switch *logFormat {
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		logger.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp: true,
+		})
+		if *logFormat != "text" {
+			logger.WithField("format", *logFormat).Warn("unknown log format, using text")
+		}
+	}
 
 	if v, err := logrus.ParseLevel(*logLevel); err != nil {
 		logger.WithError(err).Warn("error setting log level")
@@ -99,10 +174,21 @@ func main() {
 		logger.SetLevel(v)
 	}
 
+	var disabled []string
+	for _, name := range strings.Split(*disabledCollectors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			disabled = append(disabled, name)
+		}
+	}
+
 	clusterConfigs := ([]*ClusterConfig)(nil)
 
 	if fileExists(*exporterConfig) {
 		cfg, err := ParseConfig(*exporterConfig)
+		if err != nil && *checkConfig {
+			printConfigError(os.Stderr, err)
+			os.Exit(1)
+		}
 		if err != nil {
 			logger.WithError(err).WithField(
 				"file", *exporterConfig,
@@ -113,31 +199,203 @@ func main() {
 		clusterConfigs = []*ClusterConfig{
 			{
 				ClusterLabel: *cephCluster,
+				ClusterName:  *cephCluster,
 				User:         *cephUser,
 				ConfigFile:   *cephConfig,
+				Keyring:      *cephKeyring,
+				Key:          *cephKey,
 			},
 		}
 	}
 
-	for _, cluster := range clusterConfigs {
-		conn := rados.NewRadosConn(
-			cluster.User,
-			cluster.ConfigFile,
+	setDefaults := func(cluster *ClusterConfig) {
+		if cluster.RadosgwAdminPath == "" {
+			cluster.RadosgwAdminPath = *radosgwAdminPath
+		}
+
+		if cluster.MetricNamespace == "" {
+			cluster.MetricNamespace = *metricNamespace
+		}
+
+		if cluster.ClusterName == "" {
+			cluster.ClusterName = defaultCephClusterName
+		}
+
+		// clusters without their own rgw_mode fall back to RGW_MODE
+		if cluster.RgwMode == nil {
+			cluster.RgwMode = rgwMode
+		}
+	}
+
+	if *checkConfig {
+		for _, cluster := range clusterConfigs {
+			setDefaults(cluster)
+		}
+
+		cfg := &Config{Cluster: clusterConfigs}
+		if err := cfg.validate(); err != nil {
+			printConfigError(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		printConfigSummary(os.Stdout, cfg)
+		os.Exit(0)
+	}
+
+	newCluster := func(cluster *ClusterConfig) (*exportedCluster, error) {
+		rules, err := relabelRules(cluster.Relabel)
+		if err != nil {
+			return nil, err
+		}
+
+		if *cluster.RgwMode != ceph.RGWModeDisabled {
+			if err := checkExecutable(cluster.RadosgwAdminPath); err != nil {
+				logger.WithError(err).WithField(
+					"cluster", cluster.ClusterLabel,
+				).Error("radosgw-admin binary is missing or not executable, RGW collection will fail")
+			}
+		}
+
+		retryConn := ceph.NewRetryConn(
+			rados.NewRadosConn(
+				cluster.User,
+				cluster.ConfigFile,
+				cluster.Keyring,
+				cluster.Key,
+				*cephRadosOpTimeout,
+				logger),
+			*cephCmdRetries,
+			*cephCmdBackoff,
 			*cephRadosOpTimeout,
 			logger)
 
-		prometheus.MustRegister(ceph.NewExporter(
-			conn,
-			cluster.ClusterLabel,
-			cluster.ConfigFile,
-			cluster.User,
-			*rgwMode,
-			logger))
+		var conn ceph.Conn = retryConn
+		if *adminSocketDir != "" {
+			conn = retryConn.WithDaemonConn(asok.NewAdminSocketConn(*adminSocketDir, cluster.ClusterName, *cephRadosOpTimeout, logger))
+		}
+
+		return &exportedCluster{
+			config: *cluster,
+			conn:   conn,
+			exporter: ceph.NewExporter(conn, cluster.ClusterLabel, ceph.ExporterOptions{
+				Namespace:          cluster.MetricNamespace,
+				Config:             cluster.ConfigFile,
+				User:               cluster.User,
+				RgwMode:            *cluster.RgwMode,
+				RadosgwAdminPath:   cluster.RadosgwAdminPath,
+				RGWTimeout:         *rgwOpTimeout,
+				RbdMirrorPools:     cluster.RbdMirrorPools,
+				RGWInstances:       cluster.rgwInstances(),
+				RGWUserMetrics:     *rgwUserMetrics,
+				CollectTimeout:     *collectTimeout,
+				CacheTTL:           *cacheTTL,
+				RefreshInterval:    *refreshInterval,
+				VersionTTL:         *versionTTL,
+				CapacityWindow:     *capacityWindow,
+				DisabledCollectors: disabled,
+				RelabelRules:       rules,
+			}, logger),
+		}, nil
+	}
 
-		logger.WithField("cluster", cluster.ClusterLabel).Info("exporting cluster")
+	clusters := &clusterSet{
+		setDefaults: setDefaults,
+		newCluster:  newCluster,
+		logger:      logger,
 	}
 
-	http.Handle(*metricsPath, promhttp.Handler())
+	for _, cluster := range clusterConfigs {
+		setDefaults(cluster)
+
+		if err := validateNamespace(cluster.MetricNamespace); err != nil {
+			logger.WithError(err).WithField(
+				"cluster", cluster.ClusterLabel,
+			).Fatal("error parsing metric namespace")
+		}
+
+		switch *cluster.RgwMode {
+		case ceph.RGWModeDisabled, ceph.RGWModeForeground, ceph.RGWModeBackground:
+		default:
+			logger.WithFields(logrus.Fields{
+				"cluster":  cluster.ClusterLabel,
+				"rgw_mode": *cluster.RgwMode,
+			}).Fatal("invalid RGW mode, must be 0 (disabled), 1 (foreground) or 2 (background)")
+		}
+
+		if err := validateExtraLabels(cluster.ExtraLabels); err != nil {
+			logger.WithError(err).WithField(
+				"cluster", cluster.ClusterLabel,
+			).Fatal("error parsing extra labels config")
+		}
+
+		if err := clusters.add(cluster); err != nil {
+			logger.WithError(err).WithField(
+				"cluster", cluster.ClusterLabel,
+			).Fatal("error exporting cluster")
+		}
+	}
+
+	if err := validateNamespace(*metricNamespace); err != nil {
+		logger.WithError(err).Fatal("error parsing METRIC_NAMESPACE")
+	}
+	// the default registry also carries the Go runtime and process metrics
+	prometheus.MustRegister(newLogLevelCollector(*metricNamespace, logger))
+	prometheus.MustRegister(newBuildInfoCollector(*metricNamespace))
+
+	// Connections are bounded in time so that slow or idle clients can't hold
+	// them open indefinitely.
+	newServer := func(handler http.Handler) *http.Server {
+		return &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: *httpReadHeaderTimeout,
+			ReadTimeout:       *httpReadTimeout,
+			WriteTimeout:      *httpWriteTimeout,
+			IdleTimeout:       *httpIdleTimeout,
+		}
+	}
+
+	basicAuthEnabled := len(*basicAuthUsername) != 0 || len(*basicAuthPassword) != 0
+	if basicAuthEnabled && (len(*basicAuthUsername) == 0 || len(*basicAuthPassword) == 0) {
+		logger.Fatal("both BASIC_AUTH_USERNAME and BASIC_AUTH_PASSWORD must be set to enable basic auth")
+	}
+
+	if len(*adminAddr) != 0 {
+		adminMux := http.NewServeMux()
+		adminMux.HandleFunc("/loglevel", logLevelHandler(logger))
+		adminMux.HandleFunc("/-/reload", reloadHandler(clusters, *exporterConfig, logger))
+
+		var adminHandler http.Handler = adminMux
+		if basicAuthEnabled {
+			adminHandler = basicAuth(adminHandler, *basicAuthUsername, *basicAuthPassword)
+		}
+
+		adminServer := newServer(adminHandler)
+		adminServer.Addr = *adminAddr
+
+		go func() {
+			logger.WithField("endpoint", *adminAddr).Info("starting ceph_exporter admin listener")
+			if err := adminServer.ListenAndServe(); err != nil {
+				logger.WithError(err).Fatal("error serving admin requests")
+			}
+		}()
+	}
+
+	// Each cluster gathers from a registry of its own so that reloads can
+	// swap clusters, whose extra labels may also differ from one another.
+	var metricsHandler http.Handler = promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, clusters}, promhttp.HandlerOpts{}),
+	)
+	var clusterHandler http.Handler = probeHandler(clusters)
+	if basicAuthEnabled {
+		metricsHandler = basicAuth(metricsHandler, *basicAuthUsername, *basicAuthPassword)
+		clusterHandler = basicAuth(clusterHandler, *basicAuthUsername, *basicAuthPassword)
+	}
+
+	http.Handle(*metricsPath, metricsHandler)
+	http.Handle("/probe", clusterHandler)
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/ready", readyHandler(clusters.conns, *readyTimeout, logger))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 			<head><title>Ceph Exporter</title></head>
@@ -151,34 +409,66 @@ func main() {
 	logger.WithField("endpoint", *metricsAddr).Info("starting ceph_exporter listener")
 
 	// Below is essentially http.ListenAndServe(), but using our custom
-	// emfileAwareTcpListener that will die if we run out of file descriptors
-	ln, err := net.Listen("tcp", *metricsAddr)
+	// emfileAwareTcpListener that will die if we run out of file descriptors,
+	// or a UNIX socket
+	ln, err := listen(*metricsAddr, logger)
 	if err != nil {
-		logrus.WithError(err).Fatal("error creating listener")
+		logger.WithError(err).Fatal("error creating listener")
 	}
 
+	server := newServer(http.DefaultServeMux)
+	serveErr := make(chan error, 1)
+
 	if len(*tlsCertPath) != 0 && len(*tlsKeyPath) != 0 {
-		server := &http.Server{
-			TLSConfig: &tls.Config{
-				GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
-					caFiles, err := tls.LoadX509KeyPair(*tlsCertPath, *tlsKeyPath)
-					if err != nil {
-						return nil, err
-					}
-
-					return &caFiles, nil
-				},
+		server.TLSConfig = &tls.Config{
+			GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				caFiles, err := tls.LoadX509KeyPair(*tlsCertPath, *tlsKeyPath)
+				if err != nil {
+					return nil, err
+				}
+
+				return &caFiles, nil
 			},
 		}
 
-		err = server.ServeTLS(emfileAwareTcpListener{ln.(*net.TCPListener), logger}, "", "")
-		if err != nil {
-			logrus.WithError(err).Fatal("error serving TLS requests")
-		}
+		go func() {
+			serveErr <- server.ServeTLS(ln, "", "")
+		}()
 	} else {
-		err = http.Serve(emfileAwareTcpListener{ln.(*net.TCPListener), logger}, nil)
-		if err != nil {
-			logrus.WithError(err).Fatal("error serving requests")
+		go func() {
+			serveErr <- server.Serve(ln)
+		}()
+	}
+
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+
+	go func() {
+		for range reloads {
+			if err := clusters.reload(*exporterConfig); err != nil {
+				logger.WithError(err).Error("error reloading config, keeping the clusters exported")
+			}
 		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		logger.WithError(err).Fatal("error serving requests")
+	case sig := <-signals:
+		logger.WithField("signal", sig).Info("shutting down ceph_exporter listener")
 	}
+
+	// scrapes in flight are given until the timeout to finish
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.WithError(err).Error("error shutting down ceph_exporter listener")
+		return
+	}
+
+	logger.Info("ceph_exporter stopped")
 }
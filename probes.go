@@ -0,0 +1,86 @@
+//   Copyright 2022 DigitalOcean
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/digitalocean/ceph_exporter/ceph"
+)
+
+// healthzHandler reports that the process is up, without contacting any
+// cluster.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyHandler reports whether the monitors of every cluster returned by
+// conns, keyed by cluster label, answer a status command within timeout.
+func readyHandler(conns func() map[string]ceph.Conn, timeout time.Duration, logger *logrus.Logger) http.HandlerFunc {
+	cmd, err := json.Marshal(map[string]interface{}{
+		"prefix": "status",
+		"format": "json",
+	})
+	if err != nil {
+		logger.WithError(err).Panic("error marshalling ceph status")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		clusterConns := conns()
+
+		results := make(map[string]chan error, len(clusterConns))
+		for cluster, conn := range clusterConns {
+			// mon commands cannot be cancelled, so a hung one is left to
+			// finish in the background
+			result := make(chan error, 1)
+			go func(conn ceph.Conn) {
+				_, _, err := conn.MonCommand(cmd)
+				result <- err
+			}(conn)
+			results[cluster] = result
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		ready := true
+		for cluster, result := range results {
+			var err error
+			select {
+			case err = <-result:
+			case <-ctx.Done():
+				err = fmt.Errorf("no answer within %s", timeout)
+			}
+
+			if err != nil {
+				logger.WithError(err).WithField("cluster", cluster).Warn("cluster not ready")
+				ready = false
+			}
+		}
+
+		if !ready {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	}
+}
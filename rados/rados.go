@@ -31,6 +31,8 @@ import (
 type RadosConn struct {
 	user       string
 	configFile string
+	keyring    string
+	key        string
 	timeout    time.Duration
 	logger     *logrus.Logger
 }
@@ -41,10 +43,15 @@ var _ ceph.Conn = &RadosConn{}
 // NewRadosConn returns a new RadosConn. Unlike the native rados.Conn, there
 // is no need to manage the connection before/after talking to the rados; it
 // is the responsibility of this *RadosConn to manage the connection.
-func NewRadosConn(user, configFile string, timeout time.Duration, logger *logrus.Logger) *RadosConn {
+//
+// The keyring and key, when set, override the keyring named in configFile;
+// key takes precedence over keyring.
+func NewRadosConn(user, configFile, keyring, key string, timeout time.Duration, logger *logrus.Logger) *RadosConn {
 	return &RadosConn{
 		user:       user,
 		configFile: configFile,
+		keyring:    keyring,
+		key:        key,
 		timeout:    timeout,
 		logger:     logger,
 	}
@@ -65,6 +72,21 @@ func (c *RadosConn) newRadosConn() (*rados.Conn, error) {
 		return nil, fmt.Errorf("error reading config file: %s", err)
 	}
 
+	if c.keyring != "" {
+		err = conn.SetConfigOption("keyring", c.keyring)
+		if err != nil {
+			return nil, fmt.Errorf("error setting keyring: %s", err)
+		}
+	}
+
+	// Ceph looks up the key before the keyring, so it wins if both are set.
+	if c.key != "" {
+		err = conn.SetConfigOption("key", c.key)
+		if err != nil {
+			return nil, fmt.Errorf("error setting key: %s", err)
+		}
+	}
+
 	tv := strconv.FormatFloat(c.timeout.Seconds(), 'f', -1, 64)
 	// Set rados_osd_op_timeout and rados_mon_op_timeout to avoid Mon
 	// and PG command hang.
@@ -82,7 +104,7 @@ func (c *RadosConn) newRadosConn() (*rados.Conn, error) {
 
 	err = conn.Connect()
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to rados: %s", err)
+		return nil, fmt.Errorf("error connecting to rados: %w", err)
 	}
 
 	return conn, nil